@@ -0,0 +1,144 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const archiveNote = "read from an archive entry: exiftool and mediainfo were not run"
+
+// runArchives analyzes the entries of each ZIP or TAR (optionally
+// gzipped) archive in paths without extracting them, printing one JSON
+// document per regular file entry. Entries are read as streams, so they
+// get what --hash-from-stream gives stdin: size, hashes and the MIME type
+// sniffed from their first bytes. It reports whether anything failed.
+func runArchives(paths []string) bool {
+	failed := false
+	for _, path := range paths {
+		err := walkArchive(path, func(name string, size int64, r io.Reader, err error) {
+			var result *MediaMetadata
+			if err == nil {
+				result, err = safeAnalyzeReader(r, path+":"+name, size)
+			}
+			if err == nil {
+				result.FileName, result.ArchivePath = path, name
+				checkExtension(result, name)
+				if message, rejected := rejectedMismatch(result); rejected {
+					fmt.Fprintf(os.Stderr, "Rejected %s:%s: %s\n", path, name, message)
+					failed = true
+				}
+				var line []byte
+				if line, err = renderResult(result); err == nil {
+					fmt.Println(string(line))
+					return
+				}
+			}
+			printArchiveError(path, name, err)
+			failed = true
+		})
+		if err != nil {
+			printArchiveError(path, "", err)
+			failed = true
+		}
+	}
+	return failed
+}
+
+// safeAnalyzeReader analyzes an archive entry, turning a panic into an
+// error like safeAnalyzePath.
+func safeAnalyzeReader(r io.Reader, label string, size int64) (result *MediaMetadata, err error) {
+	defer recoverAnalysis(label, &err)
+	return analyzeReader(r, label, size, archiveNote)
+}
+
+func printArchiveError(path, name string, err error) {
+	line, marshalErr := json.Marshal(errorRecord{FileName: relativePath(path), ArchivePath: name, Error: err.Error(), Notes: errorNotes(err)})
+	if marshalErr != nil {
+		line, _ = json.Marshal(errorRecord{Error: marshalErr.Error()})
+	}
+	fmt.Println(string(line))
+}
+
+var errUnknownArchive = errors.New("not a .zip, .tar, .tar.gz or .tgz archive")
+
+// walkArchive calls visit with every regular file in the archive at
+// path, whose format is chosen by its extension. visit must be done with
+// r before it returns; err reports an entry that couldn't be opened.
+func walkArchive(path string, visit func(name string, size int64, r io.Reader, err error)) error {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return walkZip(path, visit)
+	case strings.HasSuffix(lower, ".tar"):
+		return walkTar(path, false, visit)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return walkTar(path, true, visit)
+	}
+	return errUnknownArchive
+}
+
+func walkZip(path string, visit func(name string, size int64, r io.Reader, err error)) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		if runCtx.Err() != nil {
+			return runCtx.Err()
+		}
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		r, err := entry.Open()
+		visit(entry.Name, int64(entry.UncompressedSize64), r, err)
+		if err == nil {
+			r.Close()
+		}
+	}
+	return nil
+}
+
+func walkTar(path string, gzipped bool, visit func(name string, size int64, r io.Reader, err error)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if runCtx.Err() != nil {
+			return runCtx.Err()
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		visit(header.Name, header.Size, tr, nil)
+	}
+}
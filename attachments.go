@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Attachment is a file carried inside a container, like a font or cover
+// in a Matroska file or an embedded file in a PDF.
+type Attachment struct {
+	Name       string `json:"name,omitempty"`
+	MimeType   string `json:"mime_type,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Executable bool   `json:"executable,omitempty"`
+}
+
+// binarySizePattern matches exiftool's placeholder for binary tags,
+// "(Binary data 1234 bytes, use -b option to extract)".
+var binarySizePattern = regexp.MustCompile(`^\(Binary data (\d+) bytes`)
+
+// executableExtensions and executableMimes identify attachments that
+// could run code when opened.
+var executableExtensions = map[string]bool{
+	".exe": true, ".dll": true, ".scr": true, ".com": true, ".bat": true,
+	".cmd": true, ".msi": true, ".ps1": true, ".vbs": true, ".js": true,
+	".jar": true, ".sh": true, ".elf": true, ".so": true, ".dylib": true,
+}
+
+var executableMimes = map[string]bool{
+	"application/x-msdownload":                      true,
+	"application/x-dosexec":                         true,
+	"application/vnd.microsoft.portable-executable": true,
+	"application/x-executable":                      true,
+	"application/x-elf":                             true,
+	"application/x-sharedlib":                       true,
+	"application/x-mach-binary":                     true,
+	"application/x-msi":                             true,
+	"application/java-archive":                      true,
+	"application/x-sh":                              true,
+	"application/javascript":                        true,
+	"text/javascript":                               true,
+}
+
+// extractAttachments lists the files attached to a container. mediainfo
+// names every Matroska attachment; exiftool adds the MIME type and size
+// of the one it reports (it keeps only the last of repeated tags), and
+// is the only source for files embedded in a PDF.
+func extractAttachments(exif, media map[string]interface{}) []Attachment {
+	var attachments []Attachment
+	for _, track := range mediaTracks(media) {
+		if track["@type"] != "General" {
+			continue
+		}
+		names := exifString(track, "Attachments")
+		if extra, ok := track["extra"].(map[string]interface{}); ok && names == "" {
+			names = exifString(extra, "Attachments")
+		}
+		for _, name := range strings.Split(names, " / ") {
+			if name = strings.TrimSpace(name); name != "" {
+				attachments = append(attachments, Attachment{Name: name})
+			}
+		}
+	}
+
+	for _, prefix := range []string{"AttachedFile", "EmbeddedFile"} {
+		found := Attachment{
+			Name:     exifString(exif, prefix+"Name", prefix+"Filename"),
+			MimeType: exifString(exif, prefix+"MIMEType", prefix+"Subtype"),
+		}
+		if m := binarySizePattern.FindStringSubmatch(exifString(exif, prefix+"Data", prefix)); m != nil {
+			found.Size, _ = strconv.ParseInt(m[1], 10, 64)
+		} else {
+			found.Size = int64(exifInt(exif, prefix+"Size"))
+		}
+		if found == (Attachment{}) {
+			continue
+		}
+		merged := false
+		for i := range attachments {
+			if attachments[i].Name == found.Name {
+				attachments[i].MimeType, attachments[i].Size = found.MimeType, found.Size
+				merged = true
+			}
+		}
+		if !merged {
+			attachments = append(attachments, found)
+		}
+	}
+
+	for i := range attachments {
+		a := &attachments[i]
+		// PDF subtypes are written as names, e.g. /application#2Fpdf
+		a.MimeType = strings.ReplaceAll(strings.TrimPrefix(a.MimeType, "/"), "#2F", "/")
+		ext := strings.ToLower(filepath.Ext(a.Name))
+		if a.MimeType == "" && ext != "" {
+			a.MimeType, _, _ = strings.Cut(mime.TypeByExtension(ext), ";")
+		}
+		a.Executable = executableExtensions[ext] || executableMimes[strings.ToLower(a.MimeType)]
+	}
+	return attachments
+}
+
+// executableAttachmentNote returns the note for attachments that could
+// run code, if there are any.
+func executableAttachmentNote(attachments []Attachment) (Note, bool) {
+	var names []string
+	for _, a := range attachments {
+		if a.Executable {
+			names = append(names, a.Name)
+		}
+	}
+	if len(names) == 0 {
+		return Note{}, false
+	}
+	return newNote(noteExecutableAttachment, fmt.Sprintf("executable attachments: %s", strings.Join(names, ", "))), true
+}
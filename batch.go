@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// batchOutput is what analyzing one file of a batch produced: the line to
+// print, if any, and whether it failed or was never started. analyzed,
+// errored and mime are for --stats.
+type batchOutput struct {
+	line     []byte
+	failed   bool
+	skipped  bool
+	analyzed bool
+	errored  bool
+	mime     string
+}
+
+// runBatch analyzes every file feed submits on up to opts.Jobs workers.
+// Results are printed in submission order, one JSON document per line, so
+// the output doesn't depend on the number of workers. Files that fail
+// produce an errorRecord and don't stop the batch. Once runCtx is done no
+// further files are started. It reports whether any file failed or was
+// left out.
+func runBatch(feed func(submit func(path, resolved string, err error)), cache *resultCache) bool {
+	jobs := max(opts.Jobs, 1)
+	pending := make(chan chan batchOutput, jobs)
+	workers := make(chan struct{}, jobs)
+	done := make(chan bool)
+
+	skipped := 0
+	var stats *batchStats
+	if opts.Stats {
+		stats = newBatchStats()
+	}
+	go func() {
+		failed := false
+		for slot := range pending {
+			out := <-slot
+			failed = failed || out.failed
+			if stats != nil {
+				stats.add(out)
+			}
+			if out.skipped {
+				skipped++
+			}
+			if out.line != nil {
+				fmt.Println(string(out.line))
+			}
+		}
+		done <- failed
+	}()
+
+	feed(func(path, resolved string, err error) {
+		slot := make(chan batchOutput, 1)
+		pending <- slot
+		if runCtx.Err() != nil {
+			slot <- batchOutput{skipped: true}
+			return
+		}
+		workers <- struct{}{}
+		go func() {
+			defer func() { <-workers }()
+			if runCtx.Err() != nil {
+				slot <- batchOutput{skipped: true}
+				return
+			}
+			slot <- processBatchFile(path, resolved, err, cache)
+		}()
+	})
+	close(pending)
+	failed := <-done
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Stopped by --timeout-total: %d files not analyzed\n", skipped)
+		failed = true
+	}
+	if stats != nil {
+		if err := stats.report(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing stats: %v\n", err)
+			failed = true
+		}
+	}
+	return failed
+}
+
+func processBatchFile(path, resolved string, err error, cache *resultCache) batchOutput {
+	if err == nil && opts.SidecarOut && !opts.Force {
+		// skip before analyzing rather than throw the work away
+		if _, statErr := os.Lstat(path + outputSidecarSuffix); statErr == nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %s exists, use --force to overwrite\n", path, path+outputSidecarSuffix)
+			return batchOutput{}
+		}
+	}
+	if err == nil {
+		var result *MediaMetadata
+		if result, err = safeAnalyzePath(path, cache); err == nil {
+			result.ResolvedPath = resolved
+			mime := result.MimeType
+			problem := hasProblems(result)
+			if opts.OnlyErrors && !problem {
+				return batchOutput{analyzed: true, mime: mime}
+			}
+			var line []byte
+			if line, err = renderResult(result); err == nil {
+				// with --only-errors every file that gets this far is one
+				failed := opts.OnlyErrors
+				if message, rejected := rejectedMismatch(result); rejected {
+					fmt.Fprintf(os.Stderr, "Rejected %s: %s\n", path, message)
+					failed = true
+				}
+				if !opts.SidecarOut {
+					return batchOutput{line: line, failed: failed, analyzed: true, mime: mime}
+				}
+				if err = writeOutputSidecar(path, line, opts.Force); err == nil {
+					return batchOutput{failed: failed, analyzed: true, mime: mime}
+				}
+			}
+		}
+	}
+
+	// a skipped pipe, device or socket is reported but fails nothing
+	var notRegular *notRegularError
+	failed := !errors.As(err, &notRegular)
+	path = relativePath(path)
+	if opts.Short {
+		return batchOutput{line: []byte(path + "  error: " + err.Error()), failed: failed, errored: failed}
+	}
+	line, marshalErr := json.Marshal(errorRecord{FileName: path, Error: err.Error(), Notes: errorNotes(err)})
+	if marshalErr != nil {
+		line, _ = json.Marshal(errorRecord{Error: marshalErr.Error()})
+	}
+	return batchOutput{line: line, failed: failed, errored: failed}
+}
+
+// safeAnalyzePath is analyzePath with a panic turned into an error, so
+// one malformed file can't end the batch.
+func safeAnalyzePath(path string, cache *resultCache) (result *MediaMetadata, err error) {
+	defer recoverAnalysis(path, &err)
+	return analyzePath(path, cache)
+}
+
+// hasProblems reports whether --only-errors should show result: the
+// analysis left warning notes or the file is truncated or encrypted.
+func hasProblems(result *MediaMetadata) bool {
+	return hasWarnings(result.Notes) || result.IsTruncated || result.MediaIsEncrypted
+}
+
+// runRecursive analyzes every file below roots, printing one JSON
+// document per line, or with --sidecar-out writing one file next to each
+// input. It reports whether any file failed.
+func runRecursive(roots []string, cache *resultCache) bool {
+	return runBatch(func(submit func(path, resolved string, err error)) {
+		w := recursiveWalker(submit)
+		for _, root := range roots {
+			w.walk(root)
+		}
+	}, cache)
+}
+
+// recursiveWalker returns a walker set up with the filters of the
+// command line.
+func recursiveWalker(visit func(path, resolved string, err error)) *walker {
+	w := newWalker(opts.FollowLinks, visit)
+	w.include = splitList(opts.Include)
+	w.exclude = splitList(opts.Exclude)
+	if opts.SidecarOut {
+		// never analyze the output of an earlier run
+		w.exclude = append(w.exclude, "*"+outputSidecarSuffix)
+	}
+	w.foldCase = opts.IgnoreCase
+	w.skipHidden = opts.IgnoreHidden
+	// pipes, devices and sockets are reported as NOT_REGULAR_FILE rather
+	// than passed over
+	w.irregular = true
+	return w
+}
+
+// runDryRun prints the files runRecursive would analyze, followed by
+// their count, without running any tool or reading any file. Walk
+// errors go to stderr. It reports whether any occurred.
+func runDryRun(roots []string) bool {
+	failed := false
+	count := 0
+	w := recursiveWalker(func(path, resolved string, err error) {
+		if err != nil {
+			failed = true
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+			return
+		}
+		if opts.SidecarOut && !opts.Force {
+			if _, err := os.Lstat(path + outputSidecarSuffix); err == nil {
+				return
+			}
+		}
+		count++
+		fmt.Println(path)
+	})
+	for _, root := range roots {
+		w.walk(root)
+	}
+	fmt.Printf("%d files\n", count)
+	return failed
+}
+
+// runStdinList analyzes the files listed one per line on r, as produced
+// by e.g. find. Blank lines and lines starting with # are skipped.
+func runStdinList(r io.Reader, cache *resultCache) bool {
+	return runBatch(func(submit func(path, resolved string, err error)) {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			path := strings.TrimRight(line, "\r\n")
+			if trimmed := strings.TrimSpace(path); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				submit(path, "", nil)
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					submit("", "", fmt.Errorf("reading file list: %w", err))
+				}
+				return
+			}
+		}
+	}, cache)
+}
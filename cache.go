@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	Version  string         `json:"version"`
+	Size     int64          `json:"size"`
+	ModTime  time.Time      `json:"mtime"`
+	TZ       string         `json:"tz,omitempty"`
+	Compute  string         `json:"compute,omitempty"`
+	FIPS     bool           `json:"fips,omitempty"`
+	ShakeLen int            `json:"shake_len,omitempty"`
+	ThumbDir string         `json:"thumb_dir,omitempty"`
+	Sidecars bool           `json:"sidecars,omitempty"`
+	ToolArgs string         `json:"tool_args,omitempty"`
+	Header   bool           `json:"header_only,omitempty"`
+	XMP      string         `json:"xmp,omitempty"`
+	XMPTime  time.Time      `json:"xmp_mtime,omitzero"`
+	Result   *MediaMetadata `json:"result"`
+}
+
+// resultCache persists analysis results between runs, keyed by absolute
+// path. An entry is only reused while the file's size and modification
+// time still match. A nil *resultCache is valid and never hits. It is
+// safe for concurrent use.
+type resultCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+func loadCache(path string) (*resultCache, error) {
+	cache := &resultCache{path: path, entries: make(map[string]cacheEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func cacheKey(filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		return abs
+	}
+	return filePath
+}
+
+func (c *resultCache) lookup(filePath string, info os.FileInfo) (*MediaMetadata, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[cacheKey(filePath)]
+	if !ok || entry.Result == nil || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil, false
+	}
+	// results of another build may lack fields or compute them differently
+	if entry.Version != cacheVersion() {
+		return nil, false
+	}
+	// --header-only asks the tools for less
+	if entry.Header != opts.HeaderOnly {
+		return nil, false
+	}
+	// the merged XMP sidecar can change without touching the file
+	if xmp, xmpTime := sidecarStamp(filePath); entry.XMP != xmp || !entry.XMPTime.Equal(xmpTime) {
+		return nil, false
+	}
+	// --double-hash means reading the file again, which a hit would skip
+	if opts.DoubleHash {
+		return nil, false
+	}
+	// dates without an offset were read in the --tz zone
+	if entry.TZ != cacheTZ() {
+		return nil, false
+	}
+	// a result only has the fields of the extractors --compute ran
+	if entry.Compute != cacheCompute() {
+		return nil, false
+	}
+	// the thumbnail is written, and the XMP sidecar merged, while
+	// analyzing, and the tool arguments can change any field
+	if entry.ThumbDir != opts.ThumbDir || entry.Sidecars != !opts.NoSidecar || entry.ToolArgs != cacheToolArgs() {
+		return nil, false
+	}
+	hashing := hashingEnabled(info.Size())
+	// An entry stored by a --no-hash run can't serve a run that wants hashes.
+	if hashing && len(entry.Result.Hashes) == 0 {
+		return nil, false
+	}
+	// A --fips entry lacks md5, sha1 and blake2b, which other runs report.
+	if hashing && entry.FIPS && !opts.FIPS {
+		return nil, false
+	}
+	// The SHAKE digests and their keys depend on --shake-len.
+	if hashing && entry.ShakeLen != opts.ShakeLen {
+		return nil, false
+	}
+	// Chunk hashes and ETags are only reusable at the same part size.
+	if hashing && (opts.ChunkSize != entry.Result.ChunkSize || opts.S3PartSize != entry.Result.S3PartSize) {
+		return nil, false
+	}
+
+	if hashing && opts.CID && entry.Result.CID == "" {
+		return nil, false
+	}
+
+	// Likewise for a pixel hash, though images it can't decode will miss
+	// every time.
+	if opts.PixelHash && entry.Result.PixelHash == "" && strings.HasPrefix(entry.Result.MimeType, "image/") {
+		return nil, false
+	}
+
+	if opts.ContentHash && entry.Result.ContentHash == "" && hasContentHash(entry.Result.MimeType) {
+		return nil, false
+	}
+
+	result := *entry.Result
+	result.FileName = filePath
+	// analyzePath rewrites the per-run notes, which mustn't reach the
+	// entry, and a READ_MISMATCH belongs to the run that reread the file
+	result.Notes = withoutNote(result.Notes, noteReadMismatch)
+	if !opts.PixelHash {
+		result.PixelHash = ""
+	}
+	if !opts.ContentHash {
+		result.ContentHash = ""
+	}
+	if !hashing {
+		result.Hashes = nil
+		result.ChunkSize, result.ChunkHashes, result.ChunkRoot = 0, nil, ""
+		result.S3PartSize, result.S3ETag = 0, ""
+	}
+	if !hashing || !opts.CID {
+		result.CID = ""
+	}
+	if opts.FIPS {
+		result.Hashes = fipsOnly(result.Hashes)
+	}
+	return &result, true
+}
+
+// cacheFormat is bumped whenever what's cached changes, so entries from
+// development builds, which share an empty version, are dropped too.
+const cacheFormat = 2
+
+// cacheVersion identifies the build and cache format entries were made by.
+var cacheVersion = sync.OnceValue(func() string {
+	info := currentBuildInfo()
+	return fmt.Sprintf("%d %s %s", cacheFormat, info.Version, info.Commit)
+})
+
+// sidecarStamp returns the XMP sidecar analyzeFile would merge for
+// filePath and its modification time, or "" if there's none or
+// --no-sidecar is set.
+func sidecarStamp(filePath string) (string, time.Time) {
+	if opts.NoSidecar {
+		return "", time.Time{}
+	}
+	sidecar := findXMPSidecar(filePath)
+	if sidecar == "" {
+		return "", time.Time{}
+	}
+	info, err := os.Stat(sidecar)
+	if err != nil {
+		return "", time.Time{}
+	}
+	return sidecar, info.ModTime()
+}
+
+func cacheTZ() string {
+	if opts.TZ == nil {
+		return ""
+	}
+	return opts.TZ.String()
+}
+
+// cacheToolArgs describes the --exiftool-args and --mediainfo-args a
+// result was made with.
+func cacheToolArgs() string {
+	if len(opts.ExiftoolArgs) == 0 && len(opts.MediainfoArgs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("exiftool %q mediainfo %q", opts.ExiftoolArgs, opts.MediainfoArgs)
+}
+
+func (c *resultCache) store(filePath string, info os.FileInfo, result *MediaMetadata) {
+	if c == nil {
+		return
+	}
+	// analyzePath goes on to set the per-run fields on result, which
+	// mustn't end up in the cache
+	stored := *result
+	stored.Notes = slices.Clone(result.Notes)
+	stored.Timings = nil
+	xmp, xmpTime := sidecarStamp(filePath)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey(filePath)] = cacheEntry{
+		Version:  cacheVersion(),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		TZ:       cacheTZ(),
+		Compute:  cacheCompute(),
+		FIPS:     opts.FIPS,
+		ShakeLen: opts.ShakeLen,
+		ThumbDir: opts.ThumbDir,
+		Sidecars: !opts.NoSidecar,
+		ToolArgs: cacheToolArgs(),
+		Header:   opts.HeaderOnly,
+		XMP:      xmp,
+		XMPTime:  xmpTime,
+		Result:   &stored,
+	}
+	c.dirty = true
+}
+
+// save writes the cache back to disk if it changed. The file is replaced
+// atomically so an interrupted run can't leave a corrupt cache behind.
+func (c *resultCache) save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".infx-cache-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.dirty = false
+	return nil
+}
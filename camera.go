@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// extractCamera fills in the gear and exposure settings a photo was
+// taken with from the standard EXIF tags.
+func extractCamera(exif map[string]interface{}, out *MediaMetadata) {
+	out.CameraMake = strings.TrimSpace(exifString(exif, "Make"))
+	out.CameraModel = strings.TrimSpace(exifString(exif, "Model"))
+	out.LensModel = strings.TrimSpace(exifString(exif, "LensModel", "LensID", "Lens"))
+	out.FocalLength = focalLength(exifString(exif, "FocalLength"))
+	out.Aperture = aperture(exif["FNumber"])
+	out.ShutterSpeed = shutterSpeed(exif["ExposureTime"])
+	out.ISO = exifInt(exif, "ISO")
+}
+
+// focalLength normalizes exiftool's "50.0 mm" to "50 mm".
+func focalLength(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	mm, err := strconv.ParseFloat(strings.TrimSuffix(fields[0], "mm"), 64)
+	if err != nil || mm <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(mm, 'f', -1, 64) + " mm"
+}
+
+// aperture formats an f-number as "f/2.8".
+func aperture(value interface{}) string {
+	var n float64
+	switch v := value.(type) {
+	case float64:
+		n = v
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(v), "f/"), 64)
+		if err != nil {
+			return ""
+		}
+		n = f
+	}
+	if n <= 0 {
+		return ""
+	}
+	return "f/" + strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// shutterSpeed formats an exposure time the way cameras show it:
+// fractions of a second as "1/250", longer exposures in seconds as "2".
+// exiftool already prints the first form, but numeric output and some
+// writers give decimal seconds.
+func shutterSpeed(value interface{}) string {
+	var seconds float64
+	switch v := value.(type) {
+	case float64:
+		seconds = v
+	case string:
+		v = strings.TrimSpace(v)
+		if num, den, ok := strings.Cut(v, "/"); ok {
+			n, err1 := strconv.ParseFloat(num, 64)
+			d, err2 := strconv.ParseFloat(den, 64)
+			if err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+				return ""
+			}
+			seconds = n / d
+		} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+			seconds = f
+		}
+	}
+	switch {
+	case seconds <= 0:
+		return ""
+	case seconds < 1:
+		return fmt.Sprintf("1/%d", int(math.Round(1/seconds)))
+	default:
+		return strconv.FormatFloat(seconds, 'f', -1, 64)
+	}
+}
+
+// sourceDevice names what most likely recorded or last wrote a file, for
+// provenance: the camera if EXIF names one, else the editing software,
+// else the application or library that muxed or encoded a video, e.g.
+// "Apple iPhone 13 Pro" or "HandBrake 1.6.1". It returns "" if nothing
+// useful is present.
+func sourceDevice(exif, media map[string]interface{}, cameraMake, cameraModel string) string {
+	if camera := cameraName(cameraMake, cameraModel); camera != "" {
+		return camera
+	}
+	candidates := []string{
+		exifString(exif, "Software", "CreatorTool"),
+		generalString(media, "Encoded_Application"),
+		exifString(exif, "Encoder", "WritingApp", "EncodingTool"),
+	}
+	for _, track := range mediaTracks(media) {
+		if track["@type"] == "Video" || track["@type"] == "Audio" {
+			library, _ := track["Encoded_Library"].(string)
+			candidates = append(candidates, library)
+		}
+	}
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		// iOS writes only its version, e.g. "16.1", as Software
+		if strings.IndexFunc(candidate, unicode.IsLetter) >= 0 {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// cameraName joins make and model, leaving out the make when the model
+// already starts with it, as in "Canon" and "Canon EOS R5" or
+// "NIKON CORPORATION" and "NIKON D850".
+func cameraName(cameraMake, cameraModel string) string {
+	if cameraModel == "" {
+		return cameraMake
+	}
+	brand, _, _ := strings.Cut(cameraMake, " ")
+	if brand == "" || strings.HasPrefix(strings.ToLower(cameraModel), strings.ToLower(brand)) {
+		return cameraModel
+	}
+	return cameraMake + " " + cameraModel
+}
+
+// generalString returns a string field of mediainfo's General track.
+func generalString(media map[string]interface{}, key string) string {
+	for _, track := range mediaTracks(media) {
+		if track["@type"] == "General" {
+			value, _ := track[key].(string)
+			return value
+		}
+	}
+	return ""
+}
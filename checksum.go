@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// checksumAlgorithms are the digests --checksum can print, each matching
+// the coreutils tool of the same name (md5sum, sha256sum, ...).
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// runChecksum prints a line per file in the format of sha256sum and its
+// siblings in text mode, so `sha256sum -c` can verify the output. Only the
+// one digest is computed and no tool is run. Like coreutils, it reports
+// unreadable files on stderr, carries on, and returns whether any failed.
+func runChecksum(algorithm string, paths []string) bool {
+	failed := false
+	for _, path := range paths {
+		sum, err := checksumFile(algorithm, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "infx: %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Print(checksumLine(sum, path))
+	}
+	return failed
+}
+
+func checksumFile(algorithm, path string) (string, error) {
+	var r io.Reader = os.Stdin
+	if path != stdinPath {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	}
+	h := checksumAlgorithms[algorithm]()
+	if _, err := io.Copy(h, contextReader{runCtx, r}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksumLine formats one line the way coreutils does: the digest, two
+// spaces and the name. A name containing a backslash, newline or carriage
+// return is escaped and the line prefixed with a backslash, so it still
+// parses as one line.
+func checksumLine(sum, path string) string {
+	if strings.ContainsAny(path, "\\\n\r") {
+		escaped := strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r").Replace(path)
+		return "\\" + sum + "  " + escaped + "\n"
+	}
+	return sum + "  " + path + "\n"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+// chunkHasher computes a digest per fixed-size chunk of everything
+// written to it. Chunk i covers bytes [i*size, (i+1)*size) of the file;
+// only the last chunk may be shorter, and an empty file has no chunks.
+type chunkHasher struct {
+	size    int64
+	newHash func() hash.Hash
+	current hash.Hash
+	filled  int64
+	written int64
+	sums    [][]byte
+}
+
+func newChunkHasher(size int64, newHash func() hash.Hash) *chunkHasher {
+	return &chunkHasher{size: size, newHash: newHash, current: newHash()}
+}
+
+func (c *chunkHasher) Write(p []byte) (int, error) {
+	n := len(p)
+	c.written += int64(n)
+	for len(p) > 0 {
+		take := min(int64(len(p)), c.size-c.filled)
+		c.current.Write(p[:take])
+		c.filled += take
+		p = p[take:]
+		if c.filled == c.size {
+			c.sums = append(c.sums, c.current.Sum(nil))
+			c.current = c.newHash()
+			c.filled = 0
+		}
+	}
+	return n, nil
+}
+
+// finish returns the digest of every chunk, including a short last one.
+func (c *chunkHasher) finish() [][]byte {
+	if c.filled > 0 {
+		c.sums = append(c.sums, c.current.Sum(nil))
+		c.current = c.newHash()
+		c.filled = 0
+	}
+	return c.sums
+}
+
+func hexDigests(sums [][]byte) []string {
+	hexSums := make([]string, len(sums))
+	for i, sum := range sums {
+		hexSums[i] = hex.EncodeToString(sum)
+	}
+	return hexSums
+}
+
+// merkleRoot returns the root of a binary SHA-256 Merkle tree over the
+// chunk digests. Adjacent pairs are hashed as sha256(left || right),
+// level by level; an odd digest at the end of a level is carried up
+// unchanged. A single chunk's root is its own digest and an empty file's
+// root is sha256 of nothing.
+func merkleRoot(sums [][]byte) string {
+	if len(sums) == 0 {
+		empty := sha256.Sum256(nil)
+		return hex.EncodeToString(empty[:])
+	}
+	level := sums
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0])
+}
+
+// s3ETag returns the ETag S3 gives an object uploaded in parts with
+// these MD5 digests: the MD5 of the concatenated part digests, followed
+// by a dash and the number of parts. An empty file is uploaded as one
+// empty part.
+func s3ETag(partSums [][]byte) string {
+	if len(partSums) == 0 {
+		empty := md5.Sum(nil)
+		partSums = [][]byte{empty[:]}
+	}
+	h := md5.New()
+	for _, sum := range partSums {
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(partSums))
+}
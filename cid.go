@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"strings"
+)
+
+// These match the defaults of `ipfs add --cid-version=1`: 256 KiB chunks
+// stored as raw leaves, joined by a balanced tree of UnixFS dag-pb nodes
+// with at most 174 links each.
+const (
+	cidChunkSize = 256 << 10
+	cidMaxLinks  = 174
+
+	codecRaw   = 0x55
+	codecDagPB = 0x70
+	mhSHA256   = 0x12
+)
+
+// cidNode is a block of the UnixFS DAG: its CID, the file bytes below it
+// and the size of the blocks below it, which is what links record.
+type cidNode struct {
+	cid      []byte
+	fileSize uint64
+	treeSize uint64
+}
+
+// fileCID returns the CIDv1 IPFS gives a file, from the sha256 digests
+// of its cidChunkSize chunks and its size. A file of at most one chunk is
+// a single raw block, so its CID is that block's.
+func fileCID(chunkSums [][]byte, size int64) string {
+	if len(chunkSums) == 0 {
+		empty := sha256.Sum256(nil)
+		chunkSums = [][]byte{empty[:]}
+	}
+	level := make([]cidNode, len(chunkSums))
+	for i, sum := range chunkSums {
+		chunk := uint64(min(size-int64(i)*cidChunkSize, cidChunkSize))
+		level[i] = cidNode{cid: encodeCID(codecRaw, sum), fileSize: chunk, treeSize: chunk}
+	}
+	// grouping each level into full nodes, the last one taking the rest,
+	// gives the same tree as the balanced layout ipfs builds depth first
+	for len(level) > 1 {
+		next := make([]cidNode, 0, (len(level)+cidMaxLinks-1)/cidMaxLinks)
+		for start := 0; start < len(level); start += cidMaxLinks {
+			next = append(next, dagPBNode(level[start:min(start+cidMaxLinks, len(level))]))
+		}
+		level = next
+	}
+	return "b" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(level[0].cid))
+}
+
+// dagPBNode builds the UnixFS file node linking children. dag-pb writes
+// the links before the data, each with an empty name.
+func dagPBNode(children []cidNode) cidNode {
+	var fileSize, treeSize uint64
+	var unixfs []byte
+	unixfs = protoVarint(unixfs, 1, 2) // Type: File
+	for _, child := range children {
+		fileSize += child.fileSize
+	}
+	unixfs = protoVarint(unixfs, 3, fileSize)
+	for _, child := range children {
+		unixfs = protoVarint(unixfs, 4, child.fileSize)
+	}
+
+	var block []byte
+	for _, child := range children {
+		var link []byte
+		link = protoBytes(link, 1, child.cid)
+		link = protoBytes(link, 2, nil)
+		link = protoVarint(link, 3, child.treeSize)
+		block = protoBytes(block, 2, link)
+		treeSize += child.treeSize
+	}
+	block = protoBytes(block, 1, unixfs)
+
+	sum := sha256.Sum256(block)
+	return cidNode{
+		cid:      encodeCID(codecDagPB, sum[:]),
+		fileSize: fileSize,
+		treeSize: treeSize + uint64(len(block)),
+	}
+}
+
+// encodeCID returns the binary CIDv1 of a block with this codec and
+// sha256 digest.
+func encodeCID(codec uint64, sum []byte) []byte {
+	cid := binary.AppendUvarint(nil, 1)
+	cid = binary.AppendUvarint(cid, codec)
+	cid = binary.AppendUvarint(cid, mhSHA256)
+	cid = binary.AppendUvarint(cid, uint64(len(sum)))
+	return append(cid, sum...)
+}
+
+func protoVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+func protoBytes(b []byte, field int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// computeFlags are --compute selectors that stand for a flag rather than
+// an extractor, so --compute all turns them on too.
+var computeFlags = map[string]*bool{
+	"pixel_hash":    &opts.PixelHash,
+	"content_hash":  &opts.ContentHash,
+	"cid":           &opts.CID,
+	"trailing_data": &opts.TrailingData,
+}
+
+// computeDeps lists the extractors whose fields a selector builds on.
+var computeDeps = map[string][]string{
+	"dimensions":   {"streams"},
+	"image":        {"dimensions"},
+	"aspect_ratio": {"dimensions"},
+}
+
+// computeSelectors returns every selector --compute accepts, sorted.
+func computeSelectors() []string {
+	var names []string
+	for _, e := range extractors {
+		names = append(names, e.Name())
+	}
+	for name := range computeFlags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// parseCompute sets opts.Compute from the --compute selectors, adding the
+// extractors they depend on, and turns on the flags selected. "all"
+// selects everything.
+func parseCompute(selectors []string) error {
+	valid := computeSelectors()
+	selected := make(map[string]bool)
+	var add func(name string)
+	add = func(name string) {
+		if selected[name] {
+			return
+		}
+		selected[name] = true
+		for _, dep := range computeDeps[name] {
+			add(dep)
+		}
+	}
+	for _, name := range selectors {
+		switch {
+		case name == "all":
+			for _, name := range valid {
+				add(name)
+			}
+		case slices.Contains(valid, name):
+			add(name)
+		default:
+			return fmt.Errorf("unknown selector %q, use all or one of: %s", name, strings.Join(valid, ", "))
+		}
+	}
+	for name, flag := range computeFlags {
+		if selected[name] {
+			*flag = true
+			delete(selected, name)
+		}
+	}
+	opts.Compute = selected
+	return nil
+}
+
+// computes reports whether the extractor e runs: without --compute every
+// extractor except the optional ones does.
+func computes(e Extractor) bool {
+	if opts.Compute == nil {
+		return !e.Optional()
+	}
+	return opts.Compute[e.Name()]
+}
+
+// cacheCompute identifies the extractors that ran, so a cached result is
+// only reused by a run selecting the same ones.
+func cacheCompute() string {
+	if opts.Compute == nil {
+		return ""
+	}
+	names := make([]string, 0, len(opts.Compute))
+	for name := range opts.Compute {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configNames are the config files looked for, in order, in the working
+// directory and then in $XDG_CONFIG_HOME/infx.
+var configNames = []string{"infx.toml", "infx.json"}
+
+// flagEnv lists flags whose default comes from the environment. An
+// environment variable that is set beats the config file.
+var flagEnv = map[string]string{
+	"exiftool-path":  "INFX_EXIFTOOL",
+	"mediainfo-path": "INFX_MEDIAINFO",
+}
+
+// toolPathKeys are the config keys naming a binary to run. They're
+// ignored in a config from the working directory, which may be an
+// untrusted download or checkout.
+var toolPathKeys = map[string]bool{
+	"exiftool-path":  true,
+	"mediainfo-path": true,
+}
+
+// findConfig returns the config file to use: explicit if given, else the
+// first of configNames found in the search path, else "". trusted is
+// false for a config found in the working directory.
+func findConfig(explicit string) (path string, trusted bool) {
+	if explicit != "" {
+		return explicit, true
+	}
+	dirs := []string{"."}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		dirs = append(dirs, filepath.Join(configHome, "infx"))
+	}
+	for i, dir := range dirs {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, i > 0
+			}
+		}
+	}
+	return "", false
+}
+
+// applyConfig sets every flag named in the config file at path that was
+// not given on the command line. Keys are flag names without dashes, so
+// a config can hold anything the command line can, e.g. no-hash = true.
+// Precedence is command line, then environment, then config file. Unless
+// the config is trusted, toolPathKeys are skipped with a warning.
+func applyConfig(path string, trusted bool) error {
+	values, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	for key, value := range values {
+		if flag.Lookup(key) == nil || key == "config" {
+			return fmt.Errorf("%s: unknown option %q", path, key)
+		}
+		if given[key] {
+			continue
+		}
+		if toolPathKeys[key] && !trusted {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s from %s: tool paths are only read from $XDG_CONFIG_HOME/infx or --config\n", key, path)
+			continue
+		}
+		if env, ok := flagEnv[key]; ok && os.Getenv(env) != "" {
+			continue
+		}
+		if err := flag.Set(key, value); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// loadConfig reads a JSON or TOML config into flag values. Lists become
+// comma separated values, the form list flags take.
+func loadConfig(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseJSONConfig(data)
+	}
+	return parseTOMLConfig(data)
+}
+
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			return nil, fmt.Errorf("unsupported value for %q", key)
+		}
+	}
+	return values, nil
+}
+
+// parseTOMLConfig reads the flat subset of TOML a config needs:
+// key = value lines with strings, numbers, booleans and arrays of those,
+// plus comments. Tables aren't supported since flags have no nesting.
+func parseTOMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripTOMLComment(line))
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			var items []string
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				parsed, err := parseTOMLScalar(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n+1, err)
+				}
+				items = append(items, parsed)
+			}
+			values[key] = strings.Join(items, ",")
+			continue
+		}
+		parsed, err := parseTOMLScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		values[key] = parsed
+	}
+	return values, nil
+}
+
+func parseTOMLScalar(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) >= 2:
+		return value[1 : len(value)-1], nil
+	case value == "true" || value == "false":
+		return value, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64); err == nil {
+		return strings.ReplaceAll(value, "_", ""), nil
+	}
+	return "", fmt.Errorf("unsupported value %s", value)
+}
+
+// stripTOMLComment drops a # comment that isn't inside a string.
+func stripTOMLComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+)
+
+var errUnsupportedContent = errors.New("no content hash for this format")
+
+// pngMetadataChunks are the ancillary PNG chunks left out of a content
+// hash: text, EXIF and modification time.
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+// hasContentHash reports whether contentHash supports files of mimeType.
+func hasContentHash(mimeType string) bool {
+	return mimeType == "image/jpeg" || mimeType == "image/png"
+}
+
+// contentHash returns the SHA-256 of a file with its metadata regions
+// skipped, so edits to a comment or EXIF tag leave it unchanged. This is
+// experimental and best-effort, and only covers two formats:
+//
+//   - JPEG: every APPn segment (JFIF, EXIF, XMP, ICC profile, Adobe,
+//     MPF) and COM segment before the first scan is skipped. The scan and
+//     everything after it is hashed as is.
+//   - PNG: tEXt, zTXt, iTXt, eXIf and tIME chunks are skipped. Nothing
+//     after IEND is hashed.
+//
+// Other formats return errUnsupportedContent.
+func contentHash(filePath, mimeType string) (string, error) {
+	var walk func(*bufio.Reader, hash.Hash) error
+	switch mimeType {
+	case "image/jpeg":
+		walk = jpegContent
+	case "image/png":
+		walk = pngContent
+	default:
+		return "", errUnsupportedContent
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if err := walk(bufio.NewReader(f), h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func jpegContent(r *bufio.Reader, h hash.Hash) error {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return errUnsupportedContent
+	}
+	h.Write(soi[:])
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil || b != 0xFF {
+			return errUnsupportedContent
+		}
+		// fill bytes may precede the marker code
+		for b == 0xFF {
+			if b, err = r.ReadByte(); err != nil {
+				return errUnsupportedContent
+			}
+		}
+		marker := b
+		if marker == 0xD9 {
+			h.Write([]byte{0xFF, marker})
+			return nil
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return errUnsupportedContent
+		}
+		segment := int64(binary.BigEndian.Uint16(length[:])) - 2
+		if segment < 0 {
+			return errUnsupportedContent
+		}
+		if marker >= 0xE0 && marker <= 0xEF || marker == 0xFE {
+			if _, err := io.CopyN(io.Discard, r, segment); err != nil {
+				return errUnsupportedContent
+			}
+			continue
+		}
+		h.Write([]byte{0xFF, marker})
+		h.Write(length[:])
+		if _, err := io.CopyN(h, r, segment); err != nil {
+			return errUnsupportedContent
+		}
+		if marker == 0xDA {
+			_, err := io.Copy(h, r)
+			return err
+		}
+	}
+}
+
+func pngContent(r *bufio.Reader, h hash.Hash) error {
+	var sig [8]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil || string(sig[:]) != "\x89PNG\r\n\x1a\n" {
+		return errUnsupportedContent
+	}
+	h.Write(sig[:])
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return errUnsupportedContent
+		}
+		// the chunk data is followed by its CRC
+		length := int64(binary.BigEndian.Uint32(header[:4])) + 4
+		kind := string(header[4:])
+		if pngMetadataChunks[kind] {
+			if _, err := io.CopyN(io.Discard, r, length); err != nil {
+				return errUnsupportedContent
+			}
+			continue
+		}
+		h.Write(header[:])
+		if _, err := io.CopyN(h, r, length); err != nil {
+			return errUnsupportedContent
+		}
+		if kind == "IEND" {
+			return nil
+		}
+	}
+}
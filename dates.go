@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// exifDateLayouts are the shapes exiftool uses for date/time values. The
+// fractional-second and offset parts are optional.
+var exifDateLayouts = []string{
+	"2006:01:02 15:04:05.999999999Z07:00",
+	"2006:01:02 15:04:05.999999999",
+	"2006:01:02 15:04:05Z07:00",
+	"2006:01:02 15:04:05",
+	"2006:01:02",
+}
+
+// localLayout formats a date whose offset isn't known: RFC 3339 without
+// the zone, as the camera's clock showed it.
+const localLayout = "2006-01-02T15:04:05.999999999"
+
+// parseExifTime parses an exiftool date. offset, when non-empty, is an
+// EXIF OffsetTime* value such as "+02:00" and is only applied if the date
+// itself carries no zone. Dates without any zone information are taken
+// to be in the --tz zone; without one, zoned is false and the time is
+// only a wall clock reading, parsed as UTC for want of anything better.
+func parseExifTime(value, offset string) (t time.Time, zoned, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.HasPrefix(value, "0000:00:00") {
+		return time.Time{}, false, false
+	}
+
+	for _, layout := range exifDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if strings.Contains(layout, "Z07:00") {
+			return t, true, true
+		}
+		if offset != "" {
+			if zone, err := time.Parse("-07:00", strings.TrimSpace(offset)); err == nil {
+				_, secs := zone.Zone()
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone("", secs))
+				return t, true, true
+			}
+		}
+		if opts.TZ != nil {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), opts.TZ)
+			return t, true, true
+		}
+		return t, false, true
+	}
+	return time.Time{}, false, false
+}
+
+// formatExifTime formats t as RFC 3339, leaving the zone out if it isn't
+// known rather than claiming UTC.
+func formatExifTime(t time.Time, zoned bool) string {
+	if !zoned {
+		return t.Format(localLayout)
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// exifTimestamp returns the first parseable date among keys, formatted by
+// formatExifTime, or "" when none is present.
+func exifTimestamp(exif map[string]interface{}, offsetKey string, keys ...string) string {
+	offset := exifString(exif, offsetKey)
+	for _, key := range keys {
+		if t, zoned, ok := parseExifTime(exifString(exif, key), offset); ok {
+			return formatExifTime(t, zoned)
+		}
+	}
+	return ""
+}
+
+// normalizeTimestamp converts a single exiftool date to RFC3339, without
+// a zone if it has none, returning the value unchanged if it can't be
+// parsed.
+func normalizeTimestamp(value string) string {
+	if t, zoned, ok := parseExifTime(value, ""); ok {
+		return formatExifTime(t, zoned)
+	}
+	return value
+}
+
+// utcTimestamp converts an RFC3339 timestamp to UTC, returning "" if it
+// doesn't parse, as for one without a zone.
+func utcTimestamp(value string) string {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
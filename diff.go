@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// similarThreshold is the largest dHash distance, out of 64 bits, at which
+// two images are still considered the same picture.
+const similarThreshold = 10
+
+type FieldDifference struct {
+	A interface{} `json:"a"`
+	B interface{} `json:"b"`
+}
+
+type DiffResult struct {
+	FileA            string                     `json:"file_a"`
+	FileB            string                     `json:"file_b"`
+	Verdict          string                     `json:"verdict"`
+	Identical        bool                       `json:"identical"`
+	HashDistance     *int                       `json:"hash_distance"`
+	FieldDifferences map[string]FieldDifference `json:"field_differences"`
+}
+
+// diffIgnoredFields are left out of the field comparison: the name always
+// differs, the raw tool output would drown out the derived fields, and
+// the file system attributes, timings, notes and environment say nothing
+// about the content.
+var diffIgnoredFields = map[string]bool{
+	"file_name":   true,
+	"fs":          true,
+	"exif":        true,
+	"media":       true,
+	"xmp_sidecar": true,
+	"thumbnail":   true,
+	"timings":     true,
+	"notes":       true,
+	"environment": true,
+}
+
+// diffFiles analyzes two files and reports whether they are byte
+// identical, perceptually similar images, or different.
+func diffFiles(pathA, pathB string, cache *resultCache) (*DiffResult, error) {
+	a, err := analyzePath(pathA, cache)
+	if err != nil {
+		return nil, err
+	}
+	b, err := analyzePath(pathB, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	identical, err := sameContent(pathA, pathB, a, b)
+	if err != nil {
+		return nil, err
+	}
+	diff := &DiffResult{
+		FileA:     pathA,
+		FileB:     pathB,
+		Identical: identical,
+	}
+
+	if hashA, err := perceptualHash(pathA); err == nil {
+		if hashB, err := perceptualHash(pathB); err == nil {
+			d := hammingDistance(hashA, hashB)
+			diff.HashDistance = &d
+		}
+	}
+
+	switch {
+	case diff.Identical:
+		diff.Verdict = "identical"
+	case diff.HashDistance != nil && *diff.HashDistance <= similarThreshold:
+		diff.Verdict = "similar"
+	default:
+		diff.Verdict = "different"
+	}
+
+	diff.FieldDifferences, err = fieldDifferences(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// sameContent compares the strongest digest both sides computed. Without
+// a common one, as under --no-hash or past --max-file-size, it compares
+// the bytes instead.
+func sameContent(pathA, pathB string, a, b *MediaMetadata) (bool, error) {
+	if a.FileSize != b.FileSize {
+		return false, nil
+	}
+	for _, name := range []string{"sha512", "sha3-512", "blake2b-512", "sha256", "sha3-256", "blake2b-256", "sha1", "md5"} {
+		if a.Hashes[name] != "" && b.Hashes[name] != "" {
+			return a.Hashes[name] == b.Hashes[name], nil
+		}
+	}
+	return sameBytes(pathA, pathB)
+}
+
+// sameBytes reports whether the files at pathA and pathB hold the same
+// bytes, stopping at the first block that differs.
+func sameBytes(pathA, pathB string) (bool, error) {
+	fa, err := os.Open(pathA)
+	if err != nil {
+		return false, fmt.Errorf("error opening file: %w", err)
+	}
+	defer fa.Close()
+	fb, err := os.Open(pathB)
+	if err != nil {
+		return false, fmt.Errorf("error opening file: %w", err)
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 256<<10)
+	bufB := make([]byte, 256<<10)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		switch {
+		case !bytes.Equal(bufA[:na], bufB[:nb]):
+			return false, nil
+		case errA == io.EOF || errA == io.ErrUnexpectedEOF:
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		case errA != nil:
+			return false, fmt.Errorf("error reading file: %w", errA)
+		case errB != nil:
+			return false, fmt.Errorf("error reading file: %w", errB)
+		}
+	}
+}
+
+// fieldDifferences compares the derived metadata of a and b by JSON path.
+func fieldDifferences(a, b *MediaMetadata) (map[string]FieldDifference, error) {
+	docA, err := toDocument(a)
+	if err != nil {
+		return nil, err
+	}
+	docB, err := toDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	for key := range diffIgnoredFields {
+		delete(docA, key)
+		delete(docB, key)
+	}
+
+	flatA := make(map[string]interface{})
+	flatB := make(map[string]interface{})
+	flattenDocument("", docA, flatA)
+	flattenDocument("", docB, flatB)
+
+	differences := make(map[string]FieldDifference)
+	for key, valueA := range flatA {
+		if valueB := flatB[key]; !reflect.DeepEqual(valueA, valueB) {
+			differences[key] = FieldDifference{A: valueA, B: valueB}
+		}
+	}
+	for key, valueB := range flatB {
+		if _, ok := flatA[key]; !ok {
+			differences[key] = FieldDifference{A: nil, B: valueB}
+		}
+	}
+	return differences, nil
+}
+
+// flattenDocument turns nested objects into dotted keys. Arrays are kept
+// as single values.
+func flattenDocument(prefix string, doc map[string]interface{}, out map[string]interface{}) {
+	for key, value := range doc {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenDocument(key, nested, out)
+			continue
+		}
+		out[key] = value
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// exifOrientations maps exiftool's printed Orientation values to the
+// numeric EXIF codes 1-8.
+var exifOrientations = map[string]int{
+	"Horizontal (normal)":                 1,
+	"Mirror horizontal":                   2,
+	"Rotate 180":                          3,
+	"Mirror vertical":                     4,
+	"Mirror horizontal and rotate 270 CW": 5,
+	"Rotate 90 CW":                        6,
+	"Mirror horizontal and rotate 90 CW":  7,
+	"Rotate 270 CW":                       8,
+}
+
+// exifOrientation returns the EXIF orientation code, or 0 if absent.
+func exifOrientation(exif map[string]interface{}) int {
+	switch v := exif["Orientation"].(type) {
+	case float64:
+		if v >= 1 && v <= 8 {
+			return int(v)
+		}
+	case string:
+		if n, ok := exifOrientations[v]; ok {
+			return n
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 1 && n <= 8 {
+			return n
+		}
+	}
+	return 0
+}
+
+// displayDimensions returns the size an image is shown at once its EXIF
+// orientation is applied. Orientations 5-8 involve a 90° or 270° turn,
+// which swaps width and height; the others only mirror or turn 180°.
+func displayDimensions(width, height, orientation int) (int, int) {
+	if orientation >= 5 && orientation <= 8 {
+		return height, width
+	}
+	return width, height
+}
+
+// pixelDimensions returns the stored width and height, preferring
+// exiftool and falling back to mediainfo's first image or video track.
+func pixelDimensions(exif, media map[string]interface{}) (int, int) {
+	width := exifInt(exif, "ImageWidth", "ExifImageWidth")
+	height := exifInt(exif, "ImageHeight", "ExifImageHeight")
+	if width > 0 && height > 0 {
+		return width, height
+	}
+	for _, track := range mediaTracks(media) {
+		if track["@type"] != "Image" && track["@type"] != "Video" {
+			continue
+		}
+		w, okW := trackFloat(track, "Width")
+		h, okH := trackFloat(track, "Height")
+		if okW && okH {
+			return int(w), int(h)
+		}
+	}
+	return 0, 0
+}
+
+// uncompressedImageMimes are image formats that store raw pixels, for
+// which bits per pixel only restates the bit depth.
+var uncompressedImageMimes = map[string]bool{
+	"image/bmp":                true,
+	"image/x-ms-bmp":           true,
+	"image/x-portable-bitmap":  true,
+	"image/x-portable-graymap": true,
+	"image/x-portable-pixmap":  true,
+	"image/x-portable-anymap":  true,
+	"image/x-tga":              true,
+}
+
+// aspectRatio reduces display dimensions to a ratio such as "16:9" or
+// "9:16" for portrait, or returns "" if either is unknown.
+func aspectRatio(width, height int) string {
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+	a, b := width, height
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return strconv.Itoa(width/a) + ":" + strconv.Itoa(height/a)
+}
+
+// imageDensity derives two quality indicators for images:
+//
+//	megapixels     = width * height / 1e6, rounded to 2 decimals
+//	bits_per_pixel = file_size * 8 / (width * height), rounded to 3 decimals
+//
+// bits_per_pixel is only computed for compressed formats. Both are zero
+// for non-images and when the dimensions are unknown.
+func imageDensity(mimeType string, fileSize int64, width, height int) (float64, float64) {
+	if !strings.HasPrefix(mimeType, "image/") || width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	pixels := float64(width) * float64(height)
+	megapixels := math.Round(pixels/1e6*100) / 100
+	if uncompressedImageMimes[mimeType] {
+		return megapixels, 0
+	}
+	bpp := math.Round(float64(fileSize)*8/pixels*1000) / 1000
+	return megapixels, bpp
+}
+
+// jpegEncoding reports whether a JPEG is "baseline" or "progressive",
+// going by exiftool's EncodingProcess, e.g. "Progressive DCT, Huffman
+// coding". Extended sequential and lossless JPEGs decode top to bottom
+// like baseline ones and are reported as such.
+func jpegEncoding(exif map[string]interface{}) string {
+	process := strings.ToLower(exifString(exif, "EncodingProcess"))
+	switch {
+	case process == "":
+		return ""
+	case strings.Contains(process, "progressive"):
+		return "progressive"
+	default:
+		return "baseline"
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestOrientation(t *testing.T) {
+	tests := []struct {
+		printed       string
+		code          int
+		width, height int
+	}{
+		{"Horizontal (normal)", 1, 4000, 3000},
+		{"Mirror horizontal", 2, 4000, 3000},
+		{"Rotate 180", 3, 4000, 3000},
+		{"Mirror vertical", 4, 4000, 3000},
+		{"Mirror horizontal and rotate 270 CW", 5, 3000, 4000},
+		{"Rotate 90 CW", 6, 3000, 4000},
+		{"Mirror horizontal and rotate 90 CW", 7, 3000, 4000},
+		{"Rotate 270 CW", 8, 3000, 4000},
+	}
+	for _, tt := range tests {
+		// exiftool prints the name by default and the number with -n
+		for _, value := range []interface{}{tt.printed, float64(tt.code)} {
+			got := exifOrientation(map[string]interface{}{"Orientation": value})
+			if got != tt.code {
+				t.Errorf("exifOrientation(%v) = %d, want %d", value, got, tt.code)
+			}
+		}
+		width, height := displayDimensions(4000, 3000, tt.code)
+		if width != tt.width || height != tt.height {
+			t.Errorf("displayDimensions(4000, 3000, %d) = %dx%d, want %dx%d", tt.code, width, height, tt.width, tt.height)
+		}
+	}
+}
+
+func TestOrientationInvalid(t *testing.T) {
+	for _, value := range []interface{}{nil, float64(0), float64(9), "9", "Unknown (0)"} {
+		if got := exifOrientation(map[string]interface{}{"Orientation": value}); got != 0 {
+			t.Errorf("exifOrientation(%v) = %d, want 0", value, got)
+		}
+	}
+	if width, height := displayDimensions(4000, 3000, 0); width != 4000 || height != 3000 {
+		t.Errorf("displayDimensions without orientation = %dx%d, want 4000x3000", width, height)
+	}
+}
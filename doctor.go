@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/rakyll/magicmime"
+)
+
+type dependencyCheck struct {
+	name   string
+	ok     bool
+	detail string
+}
+
+func checkTool(tool string) dependencyCheck {
+	path, err := exec.LookPath(toolPath(tool))
+	if err != nil {
+		return dependencyCheck{tool, false, fmt.Sprintf("%s not found", toolPath(tool))}
+	}
+	v := toolVersion(tool)
+	if v == "" {
+		return dependencyCheck{tool, false, fmt.Sprintf("%s found but --version probe failed", path)}
+	}
+	return dependencyCheck{tool, true, fmt.Sprintf("%s (%s)", path, v)}
+}
+
+func checkLibmagic() dependencyCheck {
+	if err := magicmime.Open(magicmime.MAGIC_MIME_TYPE); err != nil {
+		return dependencyCheck{"libmagic", false, err.Error()}
+	}
+	magicmime.Close()
+	return dependencyCheck{"libmagic", true, fmt.Sprintf("opened successfully (%s)", libmagicVersion())}
+}
+
+// runDoctor checks every external dependency and prints a report. It
+// returns false if any of them is unusable.
+func runDoctor() bool {
+	checks := []dependencyCheck{
+		checkTool("exiftool"),
+		checkTool("mediainfo"),
+		checkLibmagic(),
+	}
+
+	healthy := true
+	for _, c := range checks {
+		status := "ok"
+		if !c.ok {
+			status = "MISSING"
+			healthy = false
+		}
+		fmt.Printf("%-8s %-10s %s\n", status, c.name, c.detail)
+	}
+	if !healthy {
+		fmt.Println("\nSome required dependencies are missing; install them or point infx at them with -exiftool-path/-mediainfo-path.")
+	}
+	return healthy
+}
@@ -0,0 +1,84 @@
+package main
+
+import "strings"
+
+type DocumentMetadata struct {
+	PageCount   int      `json:"page_count,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Producer    string   `json:"producer,omitempty"`
+	Created     string   `json:"created,omitempty"`
+	CreatedUTC  string   `json:"created_utc,omitempty"`
+	Modified    string   `json:"modified,omitempty"`
+	ModifiedUTC string   `json:"modified_utc,omitempty"`
+	PDF         *PDFInfo `json:"pdf,omitempty"`
+}
+
+func isDocumentMime(mimeType string) bool {
+	switch mimeType {
+	case "application/pdf", "application/msword", "application/rtf",
+		"application/vnd.ms-excel", "application/vnd.ms-powerpoint":
+		return true
+	}
+	return strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument.")
+}
+
+// extractDocument collects document properties exiftool already reports
+// for PDFs and office files. It returns nil for any other MIME type.
+func extractDocument(mimeType string, exif map[string]interface{}) *DocumentMetadata {
+	if !isDocumentMime(mimeType) {
+		return nil
+	}
+
+	doc := &DocumentMetadata{
+		PageCount: exifInt(exif, "PageCount", "Pages"),
+		Title:     exifString(exif, "Title"),
+		Created:   normalizeTimestamp(exifString(exif, "CreateDate", "CreationDate")),
+		Modified:  normalizeTimestamp(exifString(exif, "ModifyDate", "ModDate")),
+	}
+
+	// PDFs use Creator for the authoring application, while office formats
+	// use it for the person who created the document.
+	if mimeType == "application/pdf" {
+		doc.Author = exifString(exif, "Author")
+		doc.Producer = exifString(exif, "Producer", "Creator")
+	} else {
+		doc.Author = exifString(exif, "Author", "Creator")
+		doc.Producer = exifString(exif, "Producer", "Application")
+	}
+	return doc
+}
+
+// pageOrFrameCount reports how many pages a document or multi-page TIFF
+// has, or how many frames an animated image has. The second result says
+// which of the two the count is: "pages" or "frames".
+func pageOrFrameCount(mimeType string, exif, media map[string]interface{}) (int, string) {
+	if isDocumentMime(mimeType) || mimeType == "image/tiff" {
+		if n := exifInt(exif, "PageCount", "Pages"); n > 0 {
+			return n, "pages"
+		}
+		if mimeType == "image/tiff" {
+			// mediainfo lists one Image track per TIFF page
+			if n := countTracks(media).Image; n > 0 {
+				return n, "pages"
+			}
+		}
+		return 0, ""
+	}
+
+	if !strings.HasPrefix(mimeType, "image/") {
+		return 0, ""
+	}
+	if n := exifInt(exif, "FrameCount", "AnimationFrames"); n > 0 {
+		return n, "frames"
+	}
+	for _, track := range mediaTracks(media) {
+		if track["@type"] == "Image" || track["@type"] == "Video" {
+			if n, ok := trackFloat(track, "FrameCount"); ok && n > 0 {
+				return int(n), "frames"
+			}
+		}
+	}
+	return 0, ""
+}
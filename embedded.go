@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+)
+
+// EmbeddedMedia is a file carried inside another one, analyzed with
+// --follow-container. Source is the exiftool tag it was extracted from.
+type EmbeddedMedia struct {
+	Source   string         `json:"source"`
+	Metadata *MediaMetadata `json:"metadata"`
+}
+
+// embeddedTags are the exiftool tags holding whole embedded files: RAW
+// previews, thumbnails, motion photo videos and cover art.
+var embeddedTags = []string{
+	"JpgFromRaw", "PreviewImage", "OtherImage", "ThumbnailImage",
+	"MotionPhotoVideo", "EmbeddedVideoFile", "EmbeddedImage",
+	"CoverArt", "Picture",
+}
+
+// maxEmbedDepth bounds how deep --follow-container descends, counting the
+// file itself as depth 0.
+const maxEmbedDepth = 3
+
+// extractEmbedded extracts each embedded file exif reports for filePath
+// to a temporary file and analyzes it, descending into what those embed
+// in turn up to maxEmbedDepth. Identical data under several tags is only
+// analyzed once. The results are named name:Tag, so nested ones read
+// like photo.dng:PreviewImage:ThumbnailImage. An embedded file that can't
+// be extracted or analyzed is left out with an EMBEDDED_ERROR note, so a
+// corrupt thumbnail doesn't cost the record of a good photo.
+func extractEmbedded(filePath, name string, exif map[string]interface{}, depth int) ([]EmbeddedMedia, []Note) {
+	if depth >= maxEmbedDepth {
+		return nil, nil
+	}
+	var embedded []EmbeddedMedia
+	var notes []Note
+	seen := make(map[[sha256.Size]byte]bool)
+	for _, tag := range embeddedTags {
+		if _, ok := exif[tag]; !ok {
+			continue
+		}
+		data, err := runCommand("exiftool", "-b", "-"+tag, fileArg(filePath))
+		if err != nil {
+			notes = append(notes, newNote(noteEmbeddedError, fmt.Sprintf("extracting %s: %v", tag, err)))
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if len(data) == 0 || seen[sum] {
+			continue
+		}
+		seen[sum] = true
+
+		result, err := analyzeEmbedded(data, name+":"+tag, depth+1)
+		if err != nil {
+			notes = append(notes, newNote(noteEmbeddedError, fmt.Sprintf("analyzing %s: %v", tag, err)))
+			continue
+		}
+		embedded = append(embedded, EmbeddedMedia{Source: tag, Metadata: result})
+	}
+	return embedded, notes
+}
+
+func analyzeEmbedded(data []byte, name string, depth int) (*MediaMetadata, error) {
+	f, err := os.CreateTemp("", "infx-embedded-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := analyzeFile(f.Name(), info)
+	if err != nil {
+		return nil, err
+	}
+	var notes []Note
+	result.Embedded, notes = extractEmbedded(f.Name(), name, result.EXIF, depth)
+	result.Notes = append(result.Notes, notes...)
+	result.FileName = name
+	return result, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Environment records what produced a result: the infx build and the
+// versions of the tools whose output it is derived from. Results that
+// differ between hosts usually differ in one of these.
+type Environment struct {
+	Infx      buildInfo `json:"infx"`
+	Exiftool  string    `json:"exiftool"`
+	MediaInfo string    `json:"mediainfo"`
+	Libmagic  string    `json:"libmagic"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+}
+
+// currentEnvironment probes the tools once per run; a tool that can't be
+// run has an empty version.
+var currentEnvironment = sync.OnceValue(func() *Environment {
+	return &Environment{
+		Infx:      currentBuildInfo(),
+		Exiftool:  toolVersion("exiftool"),
+		MediaInfo: toolVersion("mediainfo"),
+		Libmagic:  libmagicVersion(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+})
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// Extractor derives fields of MediaMetadata from the raw exiftool and
+// mediainfo output. Extractors run after the basic file facts (name,
+// size, MIME type, hashes) are filled in, so they can rely on those.
+type Extractor interface {
+	// Name is the --compute selector that runs the extractor.
+	Name() string
+	// Optional extractors only run when --compute asks for them.
+	Optional() bool
+	// Matches reports whether the extractor applies to a MIME type.
+	Matches(mime string) bool
+	Extract(exif, media map[string]interface{}, out *MediaMetadata)
+}
+
+// extractor builds an Extractor from plain functions. A nil match
+// applies to every MIME type.
+type extractor struct {
+	name     string
+	optional bool
+	match    func(mime string) bool
+	extract  func(exif, media map[string]interface{}, out *MediaMetadata)
+}
+
+func (e extractor) Name() string {
+	return e.name
+}
+
+func (e extractor) Optional() bool {
+	return e.optional
+}
+
+func (e extractor) Matches(mime string) bool {
+	return e.match == nil || e.match(mime)
+}
+
+func (e extractor) Extract(exif, media map[string]interface{}, out *MediaMetadata) {
+	e.extract(exif, media, out)
+}
+
+func mimePrefix(prefix string) func(string) bool {
+	return func(mime string) bool { return strings.HasPrefix(mime, prefix) }
+}
+
+// extractors is the registry runExtractors walks, in order. Later
+// extractors may use fields set by earlier ones.
+var extractors = []Extractor{
+	extractor{name: "streams", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.ContainerFormat = containerFormat(media)
+		out.Duration = extractDuration(exif, media)
+		out.OverallBitRate = overallBitRate(media)
+		out.OverallBitRateHuman = humanBitRate(out.OverallBitRate)
+		out.TrackCounts = countTracks(media)
+		out.Video, out.Audio, out.Subtitles = extractStreams(media)
+	}},
+	extractor{name: "dates", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		// each date has its own offset tag: CreateDate is when the image
+		// was digitized
+		out.CreatedAt = cmp.Or(exifTimestamp(exif, "OffsetTimeOriginal", "DateTimeOriginal"), exifTimestamp(exif, "OffsetTimeDigitized", "CreateDate"))
+		out.ModifiedAt = exifTimestamp(exif, "OffsetTime", "ModifyDate")
+		if opts.TZ != nil {
+			out.CreatedAtUTC, out.ModifiedAtUTC = utcTimestamp(out.CreatedAt), utcTimestamp(out.ModifiedAt)
+		}
+	}},
+	extractor{name: "gps", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.GPS = extractGPS(exif)
+	}},
+	extractor{name: "camera", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		extractCamera(exif, out)
+		out.SourceDevice = sourceDevice(exif, media, out.CameraMake, out.CameraModel)
+	}},
+	extractor{name: "animation", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.MediaIsAnimation = isAnimation(out.MimeType, exif, media)
+		out.StickerKind = stickerKind(out.MimeType, out.MediaIsAnimation)
+	}},
+	extractor{name: "encryption", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.MediaIsEncrypted = isEncrypted(media)
+		if out.MediaIsEncrypted {
+			out.Notes = append(out.Notes, newNote(noteEncrypted, "the media streams are encrypted"))
+		}
+	}},
+	extractor{name: "audio_only", match: mimePrefix("video/"), extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.MediaVideoWithAudioOnly = isVideoWithAudioOnly(out.MimeType, exif, media)
+	}},
+	extractor{name: "truncation", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.IsTruncated = isTruncated(out.FileSize, out.ExifWarnings, media)
+		if out.IsTruncated {
+			out.Notes = append(out.Notes, newNote(noteTruncated, "the file appears to be cut short"))
+		}
+	}},
+	extractor{name: "dimensions", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.Width, out.Height = pixelDimensions(exif, media)
+		out.Orientation = exifOrientation(exif)
+		out.DisplayWidth, out.DisplayHeight = displayDimensions(out.Width, out.Height, out.Orientation)
+		// videos carry a rotation matrix instead of an EXIF orientation
+		if out.Orientation == 0 && len(out.Video) > 0 {
+			out.DisplayWidth, out.DisplayHeight = rotatedDimensions(out.Width, out.Height, out.Video[0].RotationDegrees)
+		}
+	}},
+	extractor{name: "image", match: mimePrefix("image/"), extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.Megapixels, out.BitsPerPixel = imageDensity(out.MimeType, out.FileSize, out.Width, out.Height)
+		out.IsMotionPhoto, out.MotionPhotoDuration = detectMotionPhoto(out.MimeType, exif, media)
+	}},
+	extractor{name: "raw", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		if out.IsRaw = isRaw(out.MimeType, out.FileExt); out.IsRaw {
+			out.Raw = extractRaw(out.FileName, exif)
+		}
+	}},
+	extractor{name: "encoding", match: mimePrefix("image/jpeg"), extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.Encoding = jpegEncoding(exif)
+	}},
+	extractor{name: "webp", match: mimePrefix("image/webp"), extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.WebP = extractWebP(out.FileName)
+	}},
+	extractor{name: "fast_start", match: isISOBMFFMime, extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.FastStart = fastStart(out.FileName, media)
+	}},
+	extractor{name: "heif", match: isHEIFMime, extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		// a plain single-image HEIC gets none of these
+		if count, depth, auxiliary := heifImages(exif, media); count > 1 {
+			out.SubImages, out.HasDepthMap, out.HasAuxiliaryImage = count, depth, auxiliary
+		}
+	}},
+	extractor{name: "count", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		count, kind := pageOrFrameCount(out.MimeType, exif, media)
+		switch kind {
+		case "pages":
+			out.PageCount = count
+		case "frames":
+			out.FrameCount = count
+		}
+		out.CountKind = kind
+	}},
+	extractor{name: "document", match: isDocumentMime, extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.Document = extractDocument(out.MimeType, exif)
+		if out.Document != nil && opts.TZ != nil {
+			out.Document.CreatedUTC = utcTimestamp(out.Document.Created)
+			out.Document.ModifiedUTC = utcTimestamp(out.Document.Modified)
+		}
+		if out.Document != nil && out.MimeType == "application/pdf" {
+			out.Document.PDF = extractPDF(out.FileName)
+		}
+	}},
+	extractor{name: "tags", match: mimePrefix("audio/"), extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.Tags = extractAudioTags(exif)
+	}},
+	extractor{name: "attachments", extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.Attachments = extractAttachments(exif, media)
+		if note, ok := executableAttachmentNote(out.Attachments); ok {
+			out.Notes = append(out.Notes, note)
+		}
+	}},
+	extractor{name: "aspect_ratio", optional: true, extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		out.AspectRatio = aspectRatio(out.DisplayWidth, out.DisplayHeight)
+	}},
+	extractor{name: "perceptual_hash", optional: true, match: mimePrefix("image/"), extract: func(exif, media map[string]interface{}, out *MediaMetadata) {
+		// formats the image package can't decode just go without
+		if hash, err := perceptualHash(out.FileName); err == nil {
+			out.PerceptualHash = fmt.Sprintf("%016x", hash)
+		}
+	}},
+}
+
+// runExtractors applies every registered extractor matching out's MIME
+// type that --compute selects.
+func runExtractors(exif, media map[string]interface{}, out *MediaMetadata) {
+	for _, e := range extractors {
+		if computes(e) && e.Matches(out.MimeType) {
+			e.Extract(exif, media, out)
+		}
+	}
+}
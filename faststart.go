@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// isobmffMimes are the ISO base media file types whose moov atom position
+// decides whether playback can start before the download finishes.
+var isobmffMimes = map[string]bool{
+	"video/mp4":       true,
+	"video/quicktime": true,
+	"video/x-m4v":     true,
+	"video/3gpp":      true,
+	"audio/mp4":       true,
+	"audio/x-m4a":     true,
+}
+
+func isISOBMFFMime(mime string) bool {
+	return isobmffMimes[mime]
+}
+
+// maxTopLevelBoxes bounds the header scan; real files have a handful of
+// top-level boxes, so anything past this isn't worth walking.
+const maxTopLevelBoxes = 1024
+
+// fastStart reports whether an MP4/MOV file has its moov atom before the
+// media data, so a player can start before the whole file is downloaded.
+// mediainfo's IsStreamable answers this when present; otherwise the
+// top-level box headers are scanned. It returns nil if neither tells.
+func fastStart(filePath string, media map[string]interface{}) *bool {
+	for _, track := range mediaTracks(media) {
+		if track["@type"] == "General" {
+			if streamable := trackFlag(track, "IsStreamable"); streamable != nil {
+				return streamable
+			}
+		}
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	return moovFirst(f)
+}
+
+// moovFirst walks the top-level boxes until it meets moov or mdat. Box
+// bodies are seeked over, so only the headers are read.
+func moovFirst(r io.ReadSeeker) *bool {
+	for i := 0; i < maxTopLevelBoxes; i++ {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// the box runs to the end of the file
+			size = -1
+		case 1:
+			var large [8]byte
+			if _, err := io.ReadFull(r, large[:]); err != nil {
+				return nil
+			}
+			size = int64(binary.BigEndian.Uint64(large[:]))
+			headerSize = 16
+		}
+
+		switch string(header[4:]) {
+		case "moov":
+			first := true
+			return &first
+		case "mdat":
+			first := false
+			return &first
+		}
+		if size < headerSize {
+			return nil
+		}
+		if _, err := r.Seek(size-headerSize, io.SeekCurrent); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
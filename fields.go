@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// toDocument converts v to the generic form encoding/json produces, so
+// output transformations can work on JSON keys instead of Go fields.
+func toDocument(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// projectFields keeps only the given dotted JSON paths of doc, e.g.
+// "hashes.sha256". Paths that don't resolve are returned as unknown.
+func projectFields(doc map[string]interface{}, paths []string) (map[string]interface{}, []string) {
+	projected := make(map[string]interface{})
+	var unknown []string
+
+	for _, path := range paths {
+		parts := strings.Split(path, ".")
+		value, ok := lookupPath(doc, parts)
+		if !ok {
+			unknown = append(unknown, path)
+			continue
+		}
+
+		target := projected
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := target[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = value
+	}
+	return projected, unknown
+}
+
+func lookupPath(doc map[string]interface{}, parts []string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range parts {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
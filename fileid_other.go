@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+func fileIdentity(info os.FileInfo) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fipsDigests are the digest name prefixes allowed with --fips: the
+// SHA-2 and SHA-3 functions of FIPS 180-4 and FIPS 202. SHA-1 is left
+// out as NIST has deprecated it, and MD5 and BLAKE2b were never approved.
+var fipsDigests = []string{"sha256", "sha512", "sha3-", "shake128-", "shake256-"}
+
+func fipsApproved(digest string) bool {
+	for _, prefix := range fipsDigests {
+		if strings.HasPrefix(digest, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fipsOnly returns the FIPS approved subset of hashes.
+func fipsOnly(hashes map[string]string) map[string]string {
+	approved := make(map[string]string, len(hashes))
+	for name, digest := range hashes {
+		if fipsApproved(name) {
+			approved[name] = digest
+		}
+	}
+	return approved
+}
+
+// checkFIPSFields rejects --fields paths that ask for a digest --fips
+// won't compute, rather than silently reporting them as unknown.
+func checkFIPSFields(paths []string) error {
+	for _, path := range paths {
+		digest, ok := strings.CutPrefix(path, "hashes.")
+		if ok && !fipsApproved(digest) {
+			return fmt.Errorf("%s is not a FIPS 140 approved digest and is not computed with --fips", digest)
+		}
+	}
+	return nil
+}
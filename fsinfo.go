@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+type FileSystemInfo struct {
+	Mode       string `json:"mode"`
+	UID        *int   `json:"uid,omitempty"`
+	GID        *int   `json:"gid,omitempty"`
+	ModifiedAt string `json:"modified_at"`
+	ChangedAt  string `json:"ctime,omitempty"`
+}
+
+// fileSystemInfo describes the file itself rather than its content.
+// Ownership and ctime are filled in where the platform exposes them.
+func fileSystemInfo(info os.FileInfo) *FileSystemInfo {
+	mode := info.Mode()
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		perm |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		perm |= 0o1000
+	}
+
+	fsi := &FileSystemInfo{
+		Mode:       fmt.Sprintf("%04o", perm),
+		ModifiedAt: info.ModTime().Format(time.RFC3339Nano),
+	}
+	platformFileSystemInfo(info, fsi)
+	return fsi
+}
@@ -0,0 +1,10 @@
+//go:build unix && !linux
+
+package main
+
+import "syscall"
+
+// changedAt leaves ctime out where Stat_t names it differently.
+func changedAt(st *syscall.Stat_t) string {
+	return ""
+}
@@ -0,0 +1,12 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+func changedAt(st *syscall.Stat_t) string {
+	return time.Unix(st.Ctim.Unix()).Format(time.RFC3339Nano)
+}
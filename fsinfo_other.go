@@ -0,0 +1,7 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+func platformFileSystemInfo(info os.FileInfo, fsi *FileSystemInfo) {}
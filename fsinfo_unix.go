@@ -0,0 +1,19 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+func platformFileSystemInfo(info os.FileInfo, fsi *FileSystemInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	uid, gid := int(st.Uid), int(st.Gid)
+	fsi.UID = &uid
+	fsi.GID = &gid
+	fsi.ChangedAt = changedAt(st)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GPSInfo is the geotag of a file in decimal form. Each field is omitted
+// when the file doesn't carry it.
+type GPSInfo struct {
+	Latitude         *float64 `json:"latitude,omitempty"`
+	Longitude        *float64 `json:"longitude,omitempty"`
+	AltitudeMeters   *float64 `json:"altitude_meters,omitempty"`
+	DirectionDegrees *float64 `json:"direction_degrees,omitempty"`
+}
+
+// dmsPattern matches exiftool's printed coordinates, e.g.
+// `40 deg 26' 46.30" N`.
+var dmsPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?) deg (\d+(?:\.\d+)?)' (\d+(?:\.\d+)?)"\s*([NSEW])?$`)
+
+// parseCoordinate converts a printed or numeric coordinate to signed
+// decimal degrees. ref is the matching GPS*Ref tag, used when the value
+// itself carries no hemisphere.
+func parseCoordinate(value interface{}, ref string) (float64, bool) {
+	var degrees float64
+	hemisphere := ""
+	switch v := value.(type) {
+	case float64:
+		degrees = v
+	case string:
+		v = strings.TrimSpace(v)
+		if m := dmsPattern.FindStringSubmatch(v); m != nil {
+			d, _ := strconv.ParseFloat(m[1], 64)
+			minutes, _ := strconv.ParseFloat(m[2], 64)
+			sec, _ := strconv.ParseFloat(m[3], 64)
+			degrees = d + minutes/60 + sec/3600
+			hemisphere = m[4]
+		} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+			degrees = f
+		} else {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if hemisphere == "" && ref != "" {
+		hemisphere = strings.ToUpper(ref[:1])
+	}
+	if (hemisphere == "S" || hemisphere == "W") && degrees > 0 {
+		degrees = -degrees
+	}
+	return math.Round(degrees*1e6) / 1e6, true
+}
+
+// parseAltitude converts GPSAltitude to signed metres. exiftool prints
+// it as "12.5 m", or as "12.5 m Below Sea Level" for the composite tag;
+// GPSAltitudeRef is "Below Sea Level" or 1 for negative altitudes.
+func parseAltitude(value, ref interface{}) (float64, bool) {
+	var meters float64
+	below := false
+	switch v := value.(type) {
+	case float64:
+		meters = v
+	case string:
+		fields := strings.Fields(v)
+		if len(fields) == 0 {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, false
+		}
+		meters = f
+		below = strings.Contains(strings.ToLower(v), "below")
+	default:
+		return 0, false
+	}
+
+	switch r := ref.(type) {
+	case float64:
+		below = below || r == 1
+	case string:
+		below = below || strings.Contains(strings.ToLower(r), "below") || strings.TrimSpace(r) == "1"
+	}
+	if below && meters > 0 {
+		meters = -meters
+	}
+	return math.Round(meters*100) / 100, true
+}
+
+// parseDirection reads GPSImgDirection as degrees in [0, 360).
+func parseDirection(value interface{}) (float64, bool) {
+	var degrees float64
+	switch v := value.(type) {
+	case float64:
+		degrees = v
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, false
+		}
+		degrees = f
+	default:
+		return 0, false
+	}
+	return math.Round(math.Mod(degrees, 360)*100) / 100, true
+}
+
+// extractGPS builds the gps object from exif, or returns nil if the file
+// has no geotag at all. QuickTime files only carry the combined
+// GPSCoordinates tag, which is split into its parts.
+func extractGPS(exif map[string]interface{}) *GPSInfo {
+	latitude, longitude, altitude := exif["GPSLatitude"], exif["GPSLongitude"], exif["GPSAltitude"]
+	if latitude == nil || longitude == nil {
+		if combined, ok := exif["GPSCoordinates"].(string); ok {
+			parts := strings.Split(combined, ", ")
+			if len(parts) >= 2 {
+				latitude, longitude = parts[0], parts[1]
+			}
+			if len(parts) >= 3 && altitude == nil {
+				altitude = parts[2]
+			}
+		}
+	}
+
+	gps := &GPSInfo{}
+	found := false
+	if lat, ok := parseCoordinate(latitude, exifString(exif, "GPSLatitudeRef")); ok {
+		gps.Latitude = &lat
+		found = true
+	}
+	if lon, ok := parseCoordinate(longitude, exifString(exif, "GPSLongitudeRef")); ok {
+		gps.Longitude = &lon
+		found = true
+	}
+	if alt, ok := parseAltitude(altitude, exif["GPSAltitudeRef"]); ok {
+		gps.AltitudeMeters = &alt
+		found = true
+	}
+	if dir, ok := parseDirection(exif["GPSImgDirection"]); ok {
+		gps.DirectionDegrees = &dir
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return gps
+}
@@ -0,0 +1,101 @@
+package main
+
+// outputGroups assigns top-level output keys to the sections of --grouped
+// output. Keys not listed here, including the raw exif and media maps,
+// stay at the top level.
+var outputGroups = []struct {
+	section string
+	keys    []string
+}{
+	{"identity", []string{
+		"file_name", "archive_path", "resolved_path", "mime_type", "mime_source",
+		"file_extension", "file_size", "file_size_human", "hashes", "chunk_size",
+		"chunk_hashes", "chunk_root", "s3_part_size", "s3_etag", "cid",
+		"sample_fingerprint", "pixel_hash", "perceptual_hash", "content_hash",
+		"fs", "xmp_sidecar",
+	}},
+	{"technical", []string{
+		"container_format", "overall_bit_rate", "overall_bit_rate_human",
+		"width", "height", "orientation", "display_width", "display_height",
+		"aspect_ratio", "megapixels", "bits_per_pixel", "encoding", "webp",
+		"fast_start", "is_raw", "raw", "track_counts", "video", "audio",
+		"subtitles", "media_is_encrypted", "is_truncated", "trailing_data",
+		"sub_images", "has_depth_map", "has_auxiliary_image", "camera_make",
+		"camera_model", "lens_model", "source_device", "focal_length",
+		"aperture", "shutter_speed", "iso",
+	}},
+	{"content", []string{
+		"duration", "created_at", "created_at_utc", "modified_at",
+		"modified_at_utc", "media_is_animation",
+		"sticker_kind", "media_video_with_audio_only", "is_motion_photo",
+		"motion_photo_duration", "gps", "page_count", "frame_count", "count_kind",
+		"document", "tags", "nfo", "attachments", "thumbnail", "embedded",
+	}},
+}
+
+// groupDocument nests the keys of doc into the sections of outputGroups.
+// Sections that would be empty are left out.
+func groupDocument(doc map[string]interface{}) map[string]interface{} {
+	for _, group := range outputGroups {
+		nested := make(map[string]interface{})
+		for _, key := range group.keys {
+			if value, ok := doc[key]; ok {
+				nested[key] = value
+				delete(doc, key)
+			}
+		}
+		if len(nested) > 0 {
+			doc[group.section] = nested
+		}
+	}
+	return doc
+}
+
+// groupSchema applies the same nesting to an object schema, so --schema
+// describes --grouped output correctly.
+func groupSchema(schema map[string]interface{}) map[string]interface{} {
+	properties := schema["properties"].(map[string]interface{})
+	required := make(map[string]bool)
+	for _, name := range schema["required"].([]string) {
+		required[name] = true
+	}
+
+	var requiredSections []string
+	for _, group := range outputGroups {
+		sectionProperties := make(map[string]interface{})
+		sectionRequired := []string{}
+		for _, key := range group.keys {
+			property, ok := properties[key]
+			if !ok {
+				continue
+			}
+			sectionProperties[key] = property
+			delete(properties, key)
+			if required[key] {
+				sectionRequired = append(sectionRequired, key)
+				delete(required, key)
+			}
+		}
+		if len(sectionProperties) > 0 {
+			properties[group.section] = map[string]interface{}{
+				"type":                 "object",
+				"properties":           sectionProperties,
+				"required":             sectionRequired,
+				"additionalProperties": false,
+			}
+		}
+		// a section holding a required key is never left out
+		if len(sectionRequired) > 0 {
+			requiredSections = append(requiredSections, group.section)
+		}
+	}
+
+	remaining := []string{}
+	for _, name := range schema["required"].([]string) {
+		if required[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	schema["required"] = append(remaining, requiredSections...)
+	return schema
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// hasherSet is one instance of every digest computeHashes reports. Sets
+// are pooled and reset between files, since in batch runs over many
+// small files allocating them, with their internal buffers, would cost
+// more than the hashing itself.
+type hasherSet struct {
+	hashes  map[string]hash.Hash
+	shakes  map[string]sha3.ShakeHash
+	writers []io.Writer
+}
+
+// copyBufferSize is the size of the read buffers computeHashes uses.
+const copyBufferSize = 256 << 10
+
+var (
+	hasherSets  sync.Pool
+	copyBuffers = sync.Pool{New: func() any {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	}}
+)
+
+func newHasherSet() (*hasherSet, error) {
+	hashes := map[string]hash.Hash{
+		"md5":      md5.New(),
+		"sha1":     sha1.New(),
+		"sha256":   sha256.New(),
+		"sha512":   sha512.New(),
+		"sha3-256": sha3.New256(),
+		"sha3-512": sha3.New512(),
+	}
+
+	blake256, err := blake2b.New256(nil)
+	if err != nil {
+		return nil, err
+	}
+	blake512, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+	hashes["blake2b-256"] = blake256
+	hashes["blake2b-512"] = blake512
+	if opts.FIPS {
+		for name := range hashes {
+			if !fipsApproved(name) {
+				delete(hashes, name)
+			}
+		}
+	}
+
+	// SHAKE output length is configurable, so the key records it
+	shakes := map[string]sha3.ShakeHash{
+		fmt.Sprintf("shake128-%d", opts.ShakeLen): sha3.NewShake128(),
+		fmt.Sprintf("shake256-%d", opts.ShakeLen): sha3.NewShake256(),
+	}
+
+	set := &hasherSet{hashes: hashes, shakes: shakes}
+	for _, h := range hashes {
+		set.writers = append(set.writers, h)
+	}
+	for _, h := range shakes {
+		set.writers = append(set.writers, h)
+	}
+	return set, nil
+}
+
+// getHasherSet returns a pooled set, reset for a new file, or a new one.
+// The options a set depends on don't change during a run.
+func getHasherSet() (*hasherSet, error) {
+	if v := hasherSets.Get(); v != nil {
+		set := v.(*hasherSet)
+		for _, h := range set.hashes {
+			h.Reset()
+		}
+		for _, h := range set.shakes {
+			h.Reset()
+		}
+		return set, nil
+	}
+	return newHasherSet()
+}
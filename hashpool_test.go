@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// BenchmarkComputeHashes measures the per-file cost of computeHashes.
+// With hasher sets and copy buffers pooled, a small file should cost a
+// few allocations rather than a fresh set of digests and a 256 KiB
+// buffer each time.
+func BenchmarkComputeHashes(b *testing.B) {
+	saved := opts
+	b.Cleanup(func() { opts = saved })
+	opts.ShakeLen = 32
+
+	for _, size := range []int{4 << 10, 1 << 20} {
+		data := bytes.Repeat([]byte{0xA5}, size)
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for b.Loop() {
+				if _, _, err := computeHashes(bytes.NewReader(data), "bench", int64(size)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// isHEIFMime matches HEIC/HEIF stills and sequences.
+func isHEIFMime(mime string) bool {
+	switch mime {
+	case "image/heic", "image/heif", "image/heic-sequence", "image/heif-sequence":
+		return true
+	}
+	return false
+}
+
+// heifImages returns how many images a HEIF container holds and whether
+// any of them is a depth map or another auxiliary image (e.g. an HDR gain
+// map or alpha plane). mediainfo lists every image item as its own Image
+// track; exiftool's SubImage* tags are the fallback.
+func heifImages(exif, media map[string]interface{}) (count int, depth, auxiliary bool) {
+	for _, track := range mediaTracks(media) {
+		switch track["@type"] {
+		case "General":
+			if s, ok := track["ImageCount"].(string); ok {
+				if n, err := strconv.Atoi(s); err == nil && n > count {
+					count = n
+				}
+			}
+		case "Image":
+			title := strings.ToLower(exifString(track, "Title", "Format_Settings", "Type"))
+			depth = depth || strings.Contains(title, "depth")
+			auxiliary = auxiliary || strings.Contains(title, "auxiliary") || strings.Contains(title, "alpha")
+		}
+	}
+	if tracks := countTracks(media).Image; tracks > count {
+		count = tracks
+	}
+
+	subImages := make(map[string]bool)
+	for key, value := range exif {
+		if prefix, _, ok := strings.Cut(key, ":"); ok && strings.HasPrefix(prefix, "SubImage") {
+			subImages[prefix] = true
+		}
+		lower := strings.ToLower(key)
+		if strings.Contains(lower, "depth") {
+			depth = true
+		}
+		if key == "AuxiliaryImageType" {
+			auxiliary = true
+			if s, ok := value.(string); ok && strings.Contains(strings.ToLower(s), "depth") {
+				depth = true
+			}
+		}
+	}
+	// the SubImage groups don't include the primary image
+	if n := len(subImages) + 1; len(subImages) > 0 && n > count {
+		count = n
+	}
+	return count, depth, auxiliary || depth
+}
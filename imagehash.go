@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// perceptualHash computes a 64-bit difference hash (dHash) of an image:
+// the image is reduced to 9x8 grayscale cells and each bit records
+// whether a cell is brighter than its right neighbour. Re-encodes and
+// resizes of the same picture end up a few bits apart. Only formats the
+// standard library decodes (JPEG, PNG, GIF) are supported.
+func perceptualHash(filePath string) (uint64, error) {
+	img, err := decodeImage(filePath)
+	if err != nil {
+		return 0, err
+	}
+	return dHash(img), nil
+}
+
+// pixelHash returns the SHA-256 of an image's decoded pixels as 8-bit
+// RGBA rows, preceded by the width and height so images with the same
+// bytes in a different shape don't collide. Files differing only in
+// metadata or container details get the same hash. The stored pixels are
+// hashed; EXIF orientation isn't applied.
+func pixelHash(filePath string) (string, error) {
+	img, err := decodeImage(filePath)
+	if err != nil {
+		return "", err
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, [2]uint32{uint32(bounds.Dx()), uint32(bounds.Dy())})
+	h.Write(rgba.Pix)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// maxDecodePixels bounds the images decodeImage decodes. The size comes
+// from the untrusted header, and a few bytes of PNG or GIF can declare
+// one that would take gigabytes to decode, per worker under --jobs.
+const maxDecodePixels = 100_000_000
+
+// decodeImage decodes the image at filePath once its header shows it's
+// within maxDecodePixels.
+func decodeImage(filePath string) (image.Image, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maxDecodePixels {
+		return nil, fmt.Errorf("image is %dx%d, more than the %d pixels decoded", config.Width, config.Height, maxDecodePixels)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+func dHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	bounds := img.Bounds()
+	var cells [h][w]float64
+	for y := 0; y < h; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/h
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/w
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/w, x0+1)
+			cells[y][x] = averageLuma(img, x0, y0, min(x1, bounds.Max.X), min(y1, bounds.Max.Y))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageLuma returns the mean Rec. 601 luma over a rectangle. Large
+// cells are sampled on a grid rather than read pixel by pixel.
+func averageLuma(img image.Image, x0, y0, x1, y1 int) float64 {
+	const maxSamples = 16
+	stepX := max((x1-x0)/maxSamples, 1)
+	stepY := max((y1-y0)/maxSamples, 1)
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
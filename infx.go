@@ -1,74 +1,362 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"crypto/md5"
-	"crypto/sha1"
 	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"hash"
 	"io"
+	"maps"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/rakyll/magicmime"
-	"golang.org/x/crypto/blake2b"
-	"golang.org/x/crypto/sha3"
 )
 
 type MediaMetadata struct {
 	FileName                string                 `json:"file_name"`
+	ArchivePath             string                 `json:"archive_path,omitempty"`
 	MimeType                string                 `json:"mime_type"`
+	MimeSource              string                 `json:"mime_source,omitempty"`
 	FileExt                 string                 `json:"file_extension"`
 	FileSize                int64                  `json:"file_size"`
 	FileSizeHuman           string                 `json:"file_size_human"`
+	ContainerFormat         string                 `json:"container_format,omitempty"`
 	Duration                string                 `json:"duration"`
+	OverallBitRate          int64                  `json:"overall_bit_rate,omitempty"`
+	OverallBitRateHuman     string                 `json:"overall_bit_rate_human,omitempty"`
+	CreatedAt               string                 `json:"created_at,omitempty"`
+	CreatedAtUTC            string                 `json:"created_at_utc,omitempty"`
+	ModifiedAt              string                 `json:"modified_at,omitempty"`
+	ModifiedAtUTC           string                 `json:"modified_at_utc,omitempty"`
+	GPS                     *GPSInfo               `json:"gps,omitempty"`
+	CameraMake              string                 `json:"camera_make,omitempty"`
+	CameraModel             string                 `json:"camera_model,omitempty"`
+	LensModel               string                 `json:"lens_model,omitempty"`
+	SourceDevice            string                 `json:"source_device,omitempty"`
+	FocalLength             string                 `json:"focal_length,omitempty"`
+	Aperture                string                 `json:"aperture,omitempty"`
+	ShutterSpeed            string                 `json:"shutter_speed,omitempty"`
+	ISO                     int                    `json:"iso,omitempty"`
 	MediaIsAnimation        bool                   `json:"media_is_animation"`
+	StickerKind             string                 `json:"sticker_kind,omitempty"`
 	MediaIsEncrypted        bool                   `json:"media_is_encrypted"`
 	MediaVideoWithAudioOnly bool                   `json:"media_video_with_audio_only"`
-	Hashes                  map[string]string      `json:"hashes"`
+	IsTruncated             bool                   `json:"is_truncated"`
+	TrailingData            *TrailingData          `json:"trailing_data,omitempty"`
+	IsMotionPhoto           bool                   `json:"is_motion_photo"`
+	MotionPhotoDuration     string                 `json:"motion_photo_duration,omitempty"`
+	SubImages               int                    `json:"sub_images,omitempty"`
+	HasDepthMap             bool                   `json:"has_depth_map,omitempty"`
+	HasAuxiliaryImage       bool                   `json:"has_auxiliary_image,omitempty"`
+	ExifWarnings            []string               `json:"exif_warnings,omitempty"`
+	ExifTruncated           map[string]int         `json:"exif_truncated,omitempty"`
+	XMPSidecar              string                 `json:"xmp_sidecar,omitempty"`
+	NFO                     *NFOInfo               `json:"nfo,omitempty"`
+	ResolvedPath            string                 `json:"resolved_path,omitempty"`
+	FS                      *FileSystemInfo        `json:"fs,omitempty"`
+	Notes                   []Note                 `json:"notes,omitempty"`
+	Hashes                  map[string]string      `json:"hashes,omitempty"`
+	ChunkSize               int64                  `json:"chunk_size,omitempty"`
+	ChunkHashes             []string               `json:"chunk_hashes,omitempty"`
+	ChunkRoot               string                 `json:"chunk_root,omitempty"`
+	S3PartSize              int64                  `json:"s3_part_size,omitempty"`
+	S3ETag                  string                 `json:"s3_etag,omitempty"`
+	SampleFingerprint       string                 `json:"sample_fingerprint,omitempty"`
+	CID                     string                 `json:"cid,omitempty"`
+	PixelHash               string                 `json:"pixel_hash,omitempty"`
+	PerceptualHash          string                 `json:"perceptual_hash,omitempty"`
+	ContentHash             string                 `json:"content_hash,omitempty"`
 	EXIF                    map[string]interface{} `json:"exif"`
 	Media                   map[string]interface{} `json:"media"`
+	Width                   int                    `json:"width,omitempty"`
+	Height                  int                    `json:"height,omitempty"`
+	Orientation             int                    `json:"orientation,omitempty"`
+	DisplayWidth            int                    `json:"display_width,omitempty"`
+	DisplayHeight           int                    `json:"display_height,omitempty"`
+	AspectRatio             string                 `json:"aspect_ratio,omitempty"`
+	Megapixels              float64                `json:"megapixels,omitempty"`
+	BitsPerPixel            float64                `json:"bits_per_pixel,omitempty"`
+	Encoding                string                 `json:"encoding,omitempty"`
+	WebP                    *WebPInfo              `json:"webp,omitempty"`
+	FastStart               *bool                  `json:"fast_start,omitempty"`
+	IsRaw                   bool                   `json:"is_raw,omitempty"`
+	Raw                     *RawInfo               `json:"raw,omitempty"`
+	PageCount               int                    `json:"page_count,omitempty"`
+	FrameCount              int                    `json:"frame_count,omitempty"`
+	CountKind               string                 `json:"count_kind,omitempty"`
+	TrackCounts             TrackCounts            `json:"track_counts"`
+	Video                   []VideoStream          `json:"video,omitempty"`
+	Audio                   []AudioStream          `json:"audio,omitempty"`
+	Subtitles               []SubtitleStream       `json:"subtitles,omitempty"`
+	Document                *DocumentMetadata      `json:"document,omitempty"`
+	Attachments             []Attachment           `json:"attachments,omitempty"`
+	Tags                    *AudioTags             `json:"tags,omitempty"`
+	Thumbnail               *ThumbnailInfo         `json:"thumbnail,omitempty"`
+	Embedded                []EmbeddedMedia        `json:"embedded,omitempty"`
+	Environment             *Environment           `json:"environment,omitempty"`
+	Timings                 *Timings               `json:"timings,omitempty"`
+}
+
+// errorRecord is emitted in place of a file's metadata when analyzing it
+// failed during a batch run. Notes classify the failures a crawl is
+// expected to run into, such as unreadable files.
+type errorRecord struct {
+	FileName    string `json:"file_name"`
+	ArchivePath string `json:"archive_path,omitempty"`
+	Error       string `json:"error"`
+	Notes       []Note `json:"notes,omitempty"`
+}
+
+// MimeResult is the output of --mime-only.
+type MimeResult struct {
+	FileName   string `json:"file_name"`
+	MimeType   string `json:"mime_type"`
+	MimeSource string `json:"mime_source,omitempty"`
+}
+
+type options struct {
+	Version       bool
+	Doctor        bool
+	Schema        bool
+	Summary       bool
+	Short         bool
+	Redact        bool
+	RedactKeys    string
+	NoHash        bool
+	HeaderOnly    bool
+	Progress      bool
+	ShakeLen      int
+	ChunkSize     int64
+	MaxFileSize   int64
+	S3PartSize    int64
+	SampleSize    int64
+	FIPS          bool
+	PixelHash     bool
+	ContentHash   bool
+	EnvDump       bool
+	EmbedEnv      bool
+	CID           bool
+	DoubleHash    bool
+	Strict        bool
+	MaxExifLen    int
+	NormalizeKeys bool
+	Checksum      string
+	Base          string
+	HashStream    bool
+	CachePath     string
+	Fields        string
+	Grouped       bool
+	KeyStyle      string
+	Diff          bool
+	MimeOnly      bool
+	Recursive     bool
+	StdinList     bool
+	DryRun        bool
+	OnlyErrors    bool
+	Jobs          int
+	FollowLinks   bool
+	Include       string
+	Exclude       string
+	IgnoreCase    bool
+	IgnoreHidden  bool
+	Archive       bool
+	FailMismatch  bool
+	Compute       map[string]bool
+	Verbose       bool
+	FollowEmbeds  bool
+	AllowFIFO     bool
+	Stats         bool
+	StatsFormat   string
+	StatsFile     string
+	Timings       bool
+	SidecarOut    bool
+	Force         bool
+	NoSidecar     bool
+	TrailingData  bool
+	NFO           bool
+	ThumbDir      string
+
+	ExiftoolPath  string
+	MediainfoPath string
+	ExiftoolArgs  []string
+	MediainfoArgs []string
+	Retries       int
+	TimeoutTotal  time.Duration
+	TZ            *time.Location
+}
+
+var opts options
+
+// runCtx bounds the whole run. --timeout-total gives it a deadline, after
+// which running tools are killed and no further files are started.
+var runCtx = context.Background()
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// toolPath maps a tool name to the binary configured for it.
+func toolPath(tool string) string {
+	switch tool {
+	case "exiftool":
+		return opts.ExiftoolPath
+	case "mediainfo":
+		return opts.MediainfoPath
+	}
+	return tool
+}
+
+// fileArg makes filePath safe to pass as a tool argument. A relative path
+// starting with "-" would be parsed as an option, and neither exiftool nor
+// mediainfo has a usable "--" end-of-options marker (exiftool reads
+// "--TAG" as a tag exclusion), so such paths get a "./" prefix instead.
+func fileArg(filePath string) string {
+	if strings.HasPrefix(filePath, "-") {
+		return "." + string(filepath.Separator) + filePath
+	}
+	return filePath
 }
 
 func runCommand(tool string, args ...string) ([]byte, error) {
-	cmd := exec.Command(tool, args...)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("%s error: %w", tool, err)
+	output, _, err := runCommandStderr(tool, args...)
+	return output, err
+}
+
+// runCommandStderr is runCommand but also returns what the tool wrote to
+// stderr, which is where exiftool reports its warnings.
+// Failed tool runs that look transient are retried up to opts.Retries
+// times, waiting retryBaseDelay, then twice as long each further attempt.
+func runCommandStderr(tool string, args ...string) ([]byte, []byte, error) {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		cmd := exec.CommandContext(runCtx, toolPath(tool), args...)
+		// don't wait on the pipes of children a killed tool left behind
+		cmd.WaitDelay = time.Second
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err == nil {
+			return output, stderr.Bytes(), nil
+		}
+		if ctxErr := runCtx.Err(); ctxErr != nil {
+			return output, stderr.Bytes(), fmt.Errorf("%s error: %w", tool, ctxErr)
+		}
+		if attempt < opts.Retries && isRetryable(err) {
+			time.Sleep(delay)
+			delay = min(delay*2, retryMaxDelay)
+			continue
+		}
+		// stdout is returned as well: some tools still print usable
+		// results when they exit non-zero.
+		return output, stderr.Bytes(), fmt.Errorf("%s error: %w", tool, err)
 	}
-	return output, nil
 }
 
-func getExifData(filePath string) (map[string]interface{}, error) {
-	out, err := runCommand("exiftool", "-j", filePath)
-	if err != nil {
-		return nil, err
+const (
+	retryBaseDelay = 200 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// isRetryable tells transient failures, like the tool being killed by a
+// signal or a system call being interrupted, from a tool deterministically
+// rejecting the file, which would only fail again.
+func isRetryable(err error) bool {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// ExitCode is -1 when the process was terminated by a signal
+		return exitErr.ExitCode() == -1
+	}
+	return errors.Is(err, os.ErrDeadlineExceeded) ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EINTR)
+}
+
+// getExifData runs exiftool on filePath. Besides the tag map it returns
+// the warnings exiftool reported, both the "Warning" tag in its JSON and
+// "Warning: ..." lines on stderr.
+func getExifData(filePath string) (map[string]interface{}, []string, error) {
+	args := []string{"-j"}
+	if opts.HeaderOnly {
+		// only read the header; don't scan to the end of the file
+		args = append(args, "-fast2")
+	}
+	args = append(args, opts.ExiftoolArgs...)
+	out, stderr, runErr := runCommandStderr("exiftool", append(args, fileArg(filePath))...)
+	if runErr != nil && len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil, runErr
 	}
 	var data []map[string]interface{}
 	if err := json.Unmarshal(out, &data); err != nil {
-		return nil, fmt.Errorf("failed to parse exiftool output: %w", err)
+		if runErr != nil {
+			return nil, nil, runErr
+		}
+		return nil, nil, fmt.Errorf("failed to parse exiftool output: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("no EXIF data found")
+	}
+	// exiftool exits non-zero on some recoverable problems but still
+	// reports what it could read. Only give up if it flagged an error.
+	if msg, ok := data[0]["Error"].(string); ok && runErr != nil {
+		return nil, nil, fmt.Errorf("%w: %s", runErr, msg)
+	}
+
+	var warnings []string
+	if w, ok := data[0]["Warning"].(string); ok && w != "" {
+		warnings = append(warnings, w)
 	}
-	if len(data) > 0 {
-		return data[0], nil
+	for _, line := range strings.Split(string(stderr), "\n") {
+		if w, ok := strings.CutPrefix(strings.TrimSpace(line), "Warning:"); ok {
+			warnings = append(warnings, strings.TrimSpace(w))
+		}
 	}
-	return nil, fmt.Errorf("no EXIF data found")
+	return data[0], warnings, nil
 }
 
-func getMediaInfo(filePath string) (map[string]interface{}, error) {
-	out, err := runCommand("mediainfo", "--Output=JSON", filePath)
+// getMediaInfo runs mediainfo on filePath. Some formats make it print
+// nothing or a plain text message instead of JSON; that isn't fatal, as
+// exiftool and hashing still work, so an empty map and a warning saying
+// why are returned instead.
+func getMediaInfo(filePath string) (map[string]interface{}, string, error) {
+	args := []string{"--Output=JSON"}
+	if opts.HeaderOnly {
+		args = append(args, "--ParseSpeed=0")
+	}
+	args = append(args, opts.MediainfoArgs...)
+	out, err := runCommand("mediainfo", append(args, fileArg(filePath))...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	trimmed := bytes.TrimSpace(out)
+	if len(trimmed) == 0 {
+		return map[string]interface{}{}, "mediainfo printed no output", nil
 	}
 	var data map[string]interface{}
-	if err := json.Unmarshal(out, &data); err != nil {
-		return nil, fmt.Errorf("failed to parse mediainfo output: %w", err)
+	if err := json.Unmarshal(trimmed, &data); err != nil {
+		message, _, _ := strings.Cut(string(trimmed), "\n")
+		if len(message) > 200 {
+			message = message[:200] + "..."
+		}
+		return map[string]interface{}{}, fmt.Sprintf("mediainfo output isn't JSON: %s", strings.TrimSpace(message)), nil
 	}
-	return data, nil
+	return data, "", nil
 }
 
 func extractDuration(exif, media map[string]interface{}) string {
@@ -103,6 +391,18 @@ func isAnimation(mimeType string, exif, media map[string]interface{}) bool {
 			return true
 		}
 	}
+	// APNG frames are counted in the acTL chunk
+	if frames, ok := exif["AnimationFrames"].(float64); ok && frames > 1 {
+		return true
+	}
+	if isWebP {
+		if _, ok := exif["AnimationLoopCount"]; ok {
+			return true
+		}
+	}
+	if mimeType == tgsMime {
+		return true
+	}
 	if anim, ok := exif["Animation"]; ok {
 		if val, ok := anim.(string); ok && (val == "Yes" || val == "True") {
 			return true
@@ -165,6 +465,77 @@ func isEncrypted(media map[string]interface{}) bool {
 	return false
 }
 
+// mediaTracks returns the track list of a mediainfo JSON document.
+func mediaTracks(media map[string]interface{}) []map[string]interface{} {
+	mediaRoot, ok := media["media"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	rawTracks, ok := mediaRoot["track"].([]interface{})
+	if !ok {
+		return nil
+	}
+	tracks := make([]map[string]interface{}, 0, len(rawTracks))
+	for _, t := range rawTracks {
+		if track, ok := t.(map[string]interface{}); ok {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
+
+// trackFloat parses a numeric mediainfo track field, which mediainfo's JSON
+// output always encodes as a string.
+func trackFloat(track map[string]interface{}, key string) (float64, bool) {
+	s, ok := track[key].(string)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// isTruncated guesses whether the file was cut short, e.g. by an aborted
+// download. Tools usually still parse the headers of such files, so we
+// look for a truncation warning or for streams whose header-declared size
+// can't fit in the bytes actually on disk.
+func isTruncated(fileSize int64, exifWarnings []string, media map[string]interface{}) bool {
+	for _, w := range exifWarnings {
+		if strings.Contains(strings.ToLower(w), "truncat") {
+			return true
+		}
+	}
+
+	var declared, expected float64
+	for _, track := range mediaTracks(media) {
+		if extra, ok := track["extra"].(map[string]interface{}); ok {
+			if v, ok := extra["IsTruncated"].(string); ok && strings.EqualFold(v, "Yes") {
+				return true
+			}
+		}
+		if tType, _ := track["@type"].(string); tType != "Video" && tType != "Audio" {
+			continue
+		}
+		if size, ok := trackFloat(track, "StreamSize"); ok {
+			declared += size
+		}
+		bitRate, hasBitRate := trackFloat(track, "BitRate")
+		duration, hasDuration := trackFloat(track, "Duration")
+		if hasBitRate && hasDuration {
+			expected += bitRate * duration / 8
+		}
+	}
+
+	size := float64(fileSize)
+	if declared > size*1.05 {
+		return true
+	}
+	return expected > 0 && size < expected/2
+}
+
 func isVideoWithAudioOnly(mimeType string, exif map[string]interface{}, media map[string]interface{}) bool {
 	if !strings.HasPrefix(mimeType, "video/") {
 		return false
@@ -208,6 +579,36 @@ func isVideoWithAudioOnly(mimeType string, exif map[string]interface{}, media ma
 	return !hasVideoTrack && !hasGeneralVideoCount && !hasVideoExifKey
 }
 
+// exifString returns the first of keys present in exif as a string.
+func exifString(exif map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := exif[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// exifInt returns the first of keys present in exif as an integer.
+func exifInt(exif map[string]interface{}, keys ...string) int {
+	for _, key := range keys {
+		switch v := exif[key].(type) {
+		case float64:
+			return int(v)
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
 func humanReadableSize(bytes int64) string {
 	const unit = 1000
 	if bytes < unit {
@@ -222,133 +623,1093 @@ func humanReadableSize(bytes int64) string {
 	return fmt.Sprintf("%.1f %s", float64(bytes)/float64(div), units[exp])
 }
 
-func getMimeType(filePath string, exif map[string]interface{}) string {
+// getMimeType picks the MIME type from, in order of trust, exiftool,
+// libmagic and Go's content sniffing of the first 512 bytes. It returns
+// the type, which of those produced it, and a warning when the sources
+// that had an opinion disagree. head holds the start of the file if the
+// caller already read it; when nil the file is opened again to sniff. An
+// empty filePath means head is all there is, as for a stream.
+func getMimeType(filePath string, exif map[string]interface{}, head []byte) (string, string, string) {
+	type candidate struct{ source, mimeType string }
+	var candidates []candidate
+
 	if mime, ok := exif["MIMEType"]; ok {
 		if s, ok := mime.(string); ok && s != "" && s != "application/unknown" {
-			return s
+			candidates = append(candidates, candidate{"exif", s})
 		}
 	}
 
-	mimeType, err := magicmime.TypeByFile(filePath)
-	if err != nil || mimeType == "" {
-		return "unknown"
+	// a stream has no path, only the head read from it
+	var mimeType string
+	var err error
+	if filePath == "" {
+		mimeType, err = magicmime.TypeByBuffer(head)
+	} else {
+		mimeType, err = magicmime.TypeByFile(filePath)
+	}
+	if err == nil && mimeType != "" {
+		candidates = append(candidates, candidate{"libmagic", mimeType})
+	}
+
+	if head == nil && filePath != "" {
+		head, _ = readHead(filePath, sniffLen)
+	}
+	if len(head) > 0 {
+		candidates = append(candidates, candidate{"sniff", http.DetectContentType(head)})
+	}
+
+	if len(candidates) == 0 {
+		return "unknown", "", ""
 	}
-	return mimeType
+
+	chosen := candidates[0]
+	var warning string
+	for _, c := range candidates[1:] {
+		if isGenericMime(c.mimeType) || isContainerMime(c.mimeType) || canonicalMime(c.mimeType) == canonicalMime(chosen.mimeType) {
+			continue
+		}
+		parts := make([]string, len(candidates))
+		for i, c := range candidates {
+			parts[i] = c.source + "=" + c.mimeType
+		}
+		warning = "MIME type sources disagree: " + strings.Join(parts, ", ")
+		break
+	}
+	return chosen.mimeType, chosen.source, warning
+}
+
+// baseMime strips parameters such as "; charset=utf-8".
+func baseMime(mimeType string) string {
+	base, _, _ := strings.Cut(mimeType, ";")
+	return strings.TrimSpace(base)
 }
 
-func computeHashes(filePath string) (map[string]string, error) {
-	hashes := map[string]hash.Hash{
-		"md5":      md5.New(),
-		"sha1":     sha1.New(),
-		"sha256":   sha256.New(),
-		"sha512":   sha512.New(),
-		"sha3-256": sha3.New256(),
-		"sha3-512": sha3.New512(),
+// isGenericMime reports whether mimeType is a catch-all answer that
+// shouldn't count as disagreeing with a more specific one.
+func isGenericMime(mimeType string) bool {
+	switch baseMime(mimeType) {
+	case "application/octet-stream", "text/plain":
+		return true
 	}
+	return false
+}
 
-	blake256, err := blake2b.New256(nil)
-	if err != nil {
-		return nil, err
+// mimeAliases maps MIME types that several names are in use for to one
+// of them, so exiftool, libmagic and the sniffer naming the same format
+// differently isn't reported as a disagreement.
+var mimeAliases = map[string]string{
+	"audio/x-wav":        "audio/wav",
+	"audio/wave":         "audio/wav",
+	"audio/vnd.wave":     "audio/wav",
+	"video/avi":          "video/x-msvideo",
+	"video/msvideo":      "video/x-msvideo",
+	"audio/x-flac":       "audio/flac",
+	"audio/mp3":          "audio/mpeg",
+	"audio/x-m4a":        "audio/mp4",
+	"image/jpg":          "image/jpeg",
+	"image/pjpeg":        "image/jpeg",
+	"image/x-ms-bmp":     "image/bmp",
+	"image/x-png":        "image/png",
+	"application/x-gzip": "application/gzip",
+	"audio/x-aiff":       "audio/aiff",
+}
+
+// canonicalMime returns the base of mimeType under the name mimeAliases
+// gives it.
+func canonicalMime(mimeType string) string {
+	base := strings.ToLower(baseMime(mimeType))
+	if alias, ok := mimeAliases[base]; ok {
+		return alias
 	}
-	blake512, err := blake2b.New512(nil)
-	if err != nil {
-		return nil, err
+	return base
+}
+
+// isContainerMime reports whether mimeType only names the container
+// format, as the sniffer does for everything ZIP based (DOCX, XLSX, EPUB,
+// JAR), every EBML file including Matroska, and Ogg, so it shouldn't
+// count as disagreeing with the specific type.
+func isContainerMime(mimeType string) bool {
+	switch baseMime(mimeType) {
+	case "application/zip", "video/webm", "application/ogg":
+		return true
 	}
-	hashes["blake2b-256"] = blake256
-	hashes["blake2b-512"] = blake512
+	return false
+}
 
+func readHead(filePath string, n int) ([]byte, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	writers := make([]io.Writer, 0, len(hashes))
-	for _, h := range hashes {
-		writers = append(writers, h)
+	head := make([]byte, n)
+	read, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:read], nil
+}
+
+// hashingEnabled reports whether a file of the given size gets hashed.
+func hashingEnabled(size int64) bool {
+	if opts.MaxFileSize > 0 && size > opts.MaxFileSize {
+		return false
 	}
+	return !opts.NoHash && !opts.HeaderOnly
+}
+
+// sniffLen is how much of a file http.DetectContentType looks at.
+const sniffLen = 512
+
+// contextReader stops reading once ctx is done, so hashing a large file
+// doesn't outlive --timeout-total.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// headWriter keeps the first bytes written to it and discards the rest.
+type headWriter struct {
+	buf []byte
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if rest := cap(w.buf) - len(w.buf); rest > 0 {
+		w.buf = append(w.buf, p[:min(rest, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// computeHashes digests everything read from r in a single pass. The
+// first sniffLen bytes seen on the way are returned too, so MIME sniffing
+// doesn't need to open the file again. The content is also written to
+// every extra writer, letting callers compute further digests in the same
+// pass. label and size, if known, are for --progress.
+func computeHashes(r io.Reader, label string, size int64, extra ...io.Writer) (map[string]string, []byte, error) {
+	set, err := getHasherSet()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer hasherSets.Put(set)
+
+	head := &headWriter{buf: make([]byte, 0, sniffLen)}
+	writers := make([]io.Writer, 0, len(set.writers)+len(extra)+2)
+	writers = append(writers, set.writers...)
+	writers = append(writers, head)
+	writers = append(writers, extra...)
+
+	var progress *progressWriter
+	if opts.Progress {
+		progress = newProgressWriter(os.Stderr, "hashing "+label, size)
+		writers = append(writers, progress)
+	}
+
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
 	multi := io.MultiWriter(writers...)
-	_, err = io.Copy(multi, file)
+	n, err := io.CopyBuffer(multi, contextReader{runCtx, r}, *buf)
+	bytesHashed.Add(n)
+	if progress != nil {
+		progress.finish()
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	results := make(map[string]string)
-	for name, h := range hashes {
+	results := make(map[string]string, len(set.hashes)+len(set.shakes))
+	for name, h := range set.hashes {
 		results[name] = hex.EncodeToString(h.Sum(nil))
 	}
-	return results, nil
+	for name, h := range set.shakes {
+		digest := make([]byte, opts.ShakeLen)
+		h.Read(digest)
+		results[name] = hex.EncodeToString(digest)
+	}
+	return results, head.buf, nil
 }
 
-func main() {
-	if err := magicmime.Open(magicmime.MAGIC_MIME_TYPE); err != nil {
-		fmt.Printf("Failed to initialize libmagic: %v\n", err)
-		os.Exit(1)
+// fileDigests holds everything the hashing pass over a file produces.
+type fileDigests struct {
+	hashes      map[string]string
+	head        []byte
+	chunkSize   int64
+	chunkHashes []string
+	chunkRoot   string
+	s3PartSize  int64
+	s3ETag      string
+	cid         string
+	// readMismatch describes how a --double-hash reread disagreed
+	readMismatch string
+}
+
+// hashFile runs hashStream over filePath. With --double-hash it reads the
+// file again to check the read was stable.
+func hashFile(filePath string) (fileDigests, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fileDigests{}, err
 	}
-	defer magicmime.Close()
+	defer file.Close()
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	digests, err := hashStream(file, filePath, size)
+	if err != nil {
+		return fileDigests{}, err
+	}
+	if opts.DoubleHash {
+		reread, err := rehash(filePath)
+		if err != nil {
+			return fileDigests{}, err
+		}
+		if first := digests.hashes["sha256"]; reread != first {
+			digests.readMismatch = fmt.Sprintf("reading the file again gave a different sha256 (%s, then %s): the storage may be failing or the file changed while it was read", first, reread)
+		}
+	}
+	return digests, nil
+}
 
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: mediainfo-cli <file>")
-		os.Exit(1)
+// hashStream runs computeHashes over r, adding the chunk hashes, S3 ETag
+// and CID when --chunk-size, --s3-etag and --cid ask for them.
+func hashStream(r io.Reader, label string, size int64, extra ...io.Writer) (fileDigests, error) {
+	var chunks, parts *chunkHasher
+	if opts.ChunkSize > 0 {
+		chunks = newChunkHasher(opts.ChunkSize, sha256.New)
+		extra = append(extra, chunks)
 	}
-	filePath := os.Args[1]
+	if opts.S3PartSize > 0 {
+		parts = newChunkHasher(opts.S3PartSize, md5.New)
+		extra = append(extra, parts)
+	}
+	var cidChunks *chunkHasher
+	if opts.CID {
+		cidChunks = newChunkHasher(cidChunkSize, sha256.New)
+		extra = append(extra, cidChunks)
+	}
+	hashes, head, err := computeHashes(r, label, size, extra...)
+	if err != nil {
+		return fileDigests{}, err
+	}
+	digests := fileDigests{hashes: hashes, head: head}
+	if chunks != nil {
+		sums := chunks.finish()
+		digests.chunkSize = opts.ChunkSize
+		digests.chunkHashes, digests.chunkRoot = hexDigests(sums), merkleRoot(sums)
+	}
+	if parts != nil {
+		digests.s3PartSize = opts.S3PartSize
+		digests.s3ETag = s3ETag(parts.finish())
+	}
+	if cidChunks != nil {
+		digests.cid = fileCID(cidChunks.finish(), cidChunks.written)
+	}
+	return digests, nil
+}
 
-	fileInfo, err := os.Stat(filePath)
+// rehash reads filePath a second time for --double-hash. sha256 alone is
+// enough to tell whether the two reads returned the same bytes.
+func rehash(filePath string) (string, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		fmt.Printf("Error getting file size: %v\n", err)
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, contextReader{runCtx, file}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (d fileDigests) apply(result *MediaMetadata) {
+	result.Hashes = d.hashes
+	result.ChunkSize, result.ChunkHashes, result.ChunkRoot = d.chunkSize, d.chunkHashes, d.chunkRoot
+	result.S3PartSize, result.S3ETag = d.s3PartSize, d.s3ETag
+	result.CID = d.cid
+	if d.readMismatch != "" {
+		result.Notes = append(result.Notes, newNote(noteReadMismatch, d.readMismatch))
+	}
+}
+
+func parseFlags() {
+	configPath := flag.String("config", "", "read default flag values from this `file` instead of searching for infx.toml/infx.json")
+	flag.BoolVar(&opts.Version, "version", false, "print version information and exit")
+	flag.BoolVar(&opts.Doctor, "doctor", false, "check that exiftool, mediainfo and libmagic are usable and exit")
+	flag.BoolVar(&opts.Schema, "schema", false, "print a JSON Schema describing the output format and exit")
+	compute := flag.String("compute", "", "comma separated `selectors` of the derived fields to compute, e.g. gps,aspect_ratio,perceptual_hash; all for everything. By default everything but aspect_ratio and perceptual_hash is computed")
+	flag.BoolVar(&opts.FailMismatch, "fail-on-mismatch", false, "exit 1 if a file's content doesn't match its extension, e.g. to screen uploads; the EXT_MISMATCH note is reported either way")
+	extensionMimesFlag := flag.String("extension-mimes", "", "comma separated `ext=mime|mime` entries replacing the MIME types accepted for those extensions by EXT_MISMATCH, e.g. jpg=image/jpeg|image/pjpeg")
+	flag.BoolVar(&opts.Archive, "archive", false, "analyze each file inside the given .zip, .tar, .tar.gz or .tgz archives without extracting them: size, hashes and sniffed MIME type, one JSON document per entry")
+	flag.BoolVar(&opts.FollowEmbeds, "follow-container", false, "also extract and analyze the media embedded in each file, such as RAW previews, motion photo videos and cover art, under embedded")
+	flag.BoolVar(&opts.AllowFIFO, "allow-fifo", false, "read named pipes through once and hash them, like --hash-from-stream does stdin, instead of skipping them with a NOT_REGULAR_FILE note; waits for a writer on each")
+	flag.BoolVar(&opts.Stats, "stats", false, "at the end of a --recursive or --stdin-list run, print the files processed, errors, bytes hashed, files per MIME type and wall time to stderr")
+	flag.StringVar(&opts.StatsFormat, "stats-format", "text", "`format` of --stats: text or prometheus, the Prometheus text exposition format")
+	flag.StringVar(&opts.StatsFile, "stats-file", "", "write --stats to `path` instead of stderr, e.g. for the node_exporter textfile collector (implies --stats)")
+	flag.BoolVar(&opts.Verbose, "verbose", false, "print diagnostics to stderr, such as the stack of a panic while analyzing a file")
+	flag.BoolVar(&opts.Timings, "timings", false, "report the milliseconds each file spent in exiftool, mediainfo and hashing under timings")
+	flag.BoolVar(&opts.EnvDump, "env-dump", false, "print the infx build and the exiftool, mediainfo and libmagic versions as JSON and exit")
+	flag.BoolVar(&opts.EmbedEnv, "embed-env", false, "add the -env-dump information to each result under environment")
+	flag.BoolVar(&opts.Diff, "diff", false, "compare two files: byte identity, perceptual distance and metadata differences")
+	flag.BoolVar(&opts.MimeOnly, "mime-only", false, "only detect the MIME type from the file header, skipping exiftool, mediainfo and hashing")
+	flag.BoolVar(&opts.Recursive, "recursive", false, "analyze every file below the given directories, one JSON document per line")
+	flag.BoolVar(&opts.Recursive, "r", false, "shorthand for -recursive")
+	flag.BoolVar(&opts.StdinList, "stdin-list", false, "analyze the files listed one per line on stdin, one JSON document per line")
+	flag.BoolVar(&opts.OnlyErrors, "only-errors", false, "with -recursive or -stdin-list, only output files that failed or have warnings, truncation or encryption, and exit 1 if there are any")
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "with -recursive, only list the files that would be analyzed and their count")
+	flag.IntVar(&opts.Jobs, "jobs", 1, "analyze up to `n` files at once with -recursive or -stdin-list")
+	flag.BoolVar(&opts.FollowLinks, "follow-symlinks", false, "follow symbolic links when recursing, guarding against link loops")
+	flag.StringVar(&opts.Include, "include", "", "with -recursive, only analyze files whose name matches one of these comma separated `globs`")
+	flag.StringVar(&opts.Exclude, "exclude", "", "with -recursive, skip files and directories whose name matches one of these comma separated `globs`")
+	flag.BoolVar(&opts.SidecarOut, "sidecar-out", false, "with -recursive, write each result to <file>"+outputSidecarSuffix+" instead of stdout")
+	flag.BoolVar(&opts.Force, "force", false, "overwrite existing -sidecar-out files")
+	flag.BoolVar(&opts.IgnoreCase, "ignore-case", false, "match -include/-exclude globs case-insensitively")
+	flag.BoolVar(&opts.IgnoreHidden, "ignore-hidden", false, "with -recursive, skip files and directories whose name starts with a dot; combine with -exclude Thumbs.db for Windows litter")
+	flag.StringVar(&opts.Checksum, "checksum", "", "only print `algorithm` (md5, sha1, sha256 or sha512) checksums of the files in the format of sha256sum and friends")
+	flag.BoolVar(&opts.Summary, "summary", false, "output only infx's own fields under a versioned schema, without the raw exif/media maps")
+	flag.BoolVar(&opts.Short, "short", false, "print one terse human readable line per file instead of JSON")
+	flag.BoolVar(&opts.NoHash, "no-hash", false, "skip computing file hashes, which dominates the run time on large files")
+	flag.StringVar(&opts.CachePath, "cache", "", "reuse results stored in this JSON `file` for files whose size and mtime are unchanged")
+	flag.BoolVar(&opts.Grouped, "grouped", false, "nest output fields into identity, technical and content sections")
+	flag.StringVar(&opts.KeyStyle, "json-key-style", "snake", "`style` of output keys: snake or camel; the raw exif/media maps keep the tools' keys")
+	flag.BoolVar(&opts.NormalizeKeys, "normalize-keys", false, "rename the keys of the raw exif and media maps to lowercase snake_case, e.g. MIMEType to mime_type")
+	flag.StringVar(&opts.Base, "base", "", "output file names relative to this `dir`, so an index stays valid when the library is mounted elsewhere")
+	flag.StringVar(&opts.Fields, "fields", "", "comma separated JSON `paths` to output, e.g. mime_type,hashes.sha256")
+	flag.BoolVar(&opts.NoSidecar, "no-sidecar", false, "don't merge metadata from a .xmp sidecar next to the file")
+	flag.BoolVar(&opts.NFO, "nfo", false, "merge title, plot, year and genres from a media server .nfo file next to the file")
+	flag.BoolVar(&opts.TrailingData, "trailing-data", false, "report data appended after the end marker of JPEG and PNG files")
+	flag.StringVar(&opts.ThumbDir, "extract-thumbnail", "", "write the embedded preview or cover art to `dir` and report it")
+	flag.StringVar(&opts.ExiftoolPath, "exiftool-path", envOr("INFX_EXIFTOOL", "exiftool"), "exiftool `binary` to run (env INFX_EXIFTOOL)")
+	flag.StringVar(&opts.MediainfoPath, "mediainfo-path", envOr("INFX_MEDIAINFO", "mediainfo"), "mediainfo `binary` to run (env INFX_MEDIAINFO)")
+	exiftoolArgs := flag.String("exiftool-args", "", "extra `arguments` for exiftool, e.g. \"-api largefilesupport=1\"")
+	mediainfoArgs := flag.String("mediainfo-args", "", "extra `arguments` for mediainfo, e.g. --ParseSpeed=1")
+	flag.BoolVar(&opts.HashStream, "hash-from-stream", false, "with - as the file, hash and sniff stdin as it is read instead of buffering it to a temporary file for exiftool and mediainfo")
+	flag.BoolVar(&opts.Strict, "strict", false, "fail files on which exiftool or mediainfo reported a warning instead of outputting them")
+	flag.BoolVar(&opts.HeaderOnly, "header-only", false, "only read file headers: skip hashing and use the tools' fast modes")
+	tz := flag.String("tz", "", "read EXIF dates without an offset as local time in this `zone` (e.g. America/New_York) and also output them in UTC")
+	flag.DurationVar(&opts.TimeoutTotal, "timeout-total", 0, "stop the whole run after this `duration` (e.g. 10m), keeping the results of files already analyzed")
+	flag.IntVar(&opts.Retries, "retries", 0, "retry exiftool/mediainfo up to `n` times with backoff when they fail transiently")
+	flag.IntVar(&opts.ShakeLen, "shake-len", 32, "output length in `bytes` of the shake128/shake256 digests")
+	chunkSize := flag.String("chunk-size", "", "also hash the file in chunks of this `size` (e.g. 4MiB), reporting each chunk's sha256 and their Merkle root")
+	s3PartSize := flag.String("s3-etag", "", "also compute the ETag S3 gives a multipart upload with parts of this `size` (e.g. 8MiB)")
+	sampleSize := flag.String("sample-hash", "", "also report a sample_fingerprint hashing the size and the first, middle and last `size` (e.g. 64KiB) of the file; a heuristic for finding likely duplicates, not collision resistant")
+	maxFileSize := flag.String("max-file-size", "", "don't hash files larger than this `size` (e.g. 2GiB); their metadata is still extracted")
+	flag.BoolVar(&opts.CID, "cid", false, "also compute the CIDv1 IPFS gives the file with default ipfs add settings")
+	flag.BoolVar(&opts.DoubleHash, "double-hash", false, "read each file twice and report a READ_MISMATCH note if the sha256 differs, to catch failing storage; doubles the read cost")
+	flag.BoolVar(&opts.PixelHash, "pixel-hash", false, "also hash the decoded pixels of JPEG, PNG and GIF images, ignoring metadata")
+	flag.BoolVar(&opts.ContentHash, "content-hash", false, "also hash JPEG and PNG files with their metadata segments skipped, so metadata edits don't look like content changes (experimental, best-effort)")
+	flag.BoolVar(&opts.FIPS, "fips", false, "only compute FIPS 140 approved digests (SHA-2 and SHA-3)")
+	flag.BoolVar(&opts.Progress, "progress", false, "print hashing progress to stderr")
+	flag.IntVar(&opts.MaxExifLen, "max-exif-value-len", 0, "truncate string EXIF values longer than `n` characters, recording their length in exif_truncated; 0 keeps them whole")
+	flag.BoolVar(&opts.Redact, "redact", false, "remove GPS, location, serial number and owner keys from the EXIF and mediainfo output")
+	flag.StringVar(&opts.RedactKeys, "redact-keys", "", "comma separated EXIF and mediainfo keys to redact, `patterns` like GPS* allowed (implies -redact)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: mediainfo-cli [flags] <file>")
+		fmt.Fprintln(flag.CommandLine.Output(), "       mediainfo-cli --diff [flags] <file> <file>")
+		fmt.Fprintln(flag.CommandLine.Output(), "       mediainfo-cli --recursive [flags] <path>...")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ... | mediainfo-cli [flags] -")
+		fmt.Fprintln(flag.CommandLine.Output(), "       mediainfo-cli --archive [flags] <archive>...")
+		fmt.Fprintln(flag.CommandLine.Output(), "       mediainfo-cli --checksum <algorithm> <file>...")
+		fmt.Fprintln(flag.CommandLine.Output(), "       find ... | mediainfo-cli --stdin-list [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if path, trusted := findConfig(*configPath); path != "" {
+		if err := applyConfig(path, trusted); err != nil {
+			fmt.Printf("Error reading config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.KeyStyle != "snake" && opts.KeyStyle != "camel" {
+		fmt.Printf("Invalid --json-key-style %q: use snake or camel\n", opts.KeyStyle)
+		os.Exit(1)
+	}
+	if opts.Short && (opts.Summary || opts.Fields != "" || opts.Grouped || opts.SidecarOut) {
+		fmt.Println("--short can't be combined with --summary, --fields, --grouped or --sidecar-out")
+		os.Exit(1)
+	}
+	if opts.StatsFile != "" {
+		opts.Stats = true
+	}
+	if opts.StatsFormat != "text" && opts.StatsFormat != "prometheus" {
+		fmt.Printf("Invalid --stats-format %q: use text or prometheus\n", opts.StatsFormat)
+		os.Exit(1)
+	}
+	if opts.MaxExifLen < 0 {
+		fmt.Println("--max-exif-value-len can't be negative")
+		os.Exit(1)
+	}
+	if opts.Base != "" {
+		if info, err := os.Stat(opts.Base); err != nil || !info.IsDir() {
+			fmt.Printf("Invalid --base %q: not a directory\n", opts.Base)
+			os.Exit(1)
+		}
+	}
+	if opts.Checksum != "" {
+		if _, ok := checksumAlgorithms[opts.Checksum]; !ok {
+			fmt.Printf("Invalid --checksum %q: use md5, sha1, sha256 or sha512\n", opts.Checksum)
+			os.Exit(1)
+		}
+		if opts.FIPS && !fipsApproved(opts.Checksum) {
+			fmt.Printf("Error: --checksum %s isn't FIPS approved\n", opts.Checksum)
+			os.Exit(1)
+		}
+	}
+	if opts.Jobs < 1 {
+		fmt.Println("--jobs must be at least 1")
 		os.Exit(1)
 	}
+	for _, extra := range []struct {
+		tool  string
+		value string
+		args  *[]string
+	}{
+		{"exiftool", *exiftoolArgs, &opts.ExiftoolArgs},
+		{"mediainfo", *mediainfoArgs, &opts.MediainfoArgs},
+	} {
+		args, err := splitToolArgs(extra.value)
+		if err == nil {
+			err = checkToolArgs(extra.tool, args)
+		}
+		if err != nil {
+			fmt.Printf("Invalid --%s-args: %v\n", extra.tool, err)
+			os.Exit(1)
+		}
+		*extra.args = args
+	}
+	if opts.ShakeLen <= 0 {
+		fmt.Println("--shake-len must be positive")
+		os.Exit(1)
+	}
+	if *tz != "" {
+		location, err := time.LoadLocation(*tz)
+		if err != nil {
+			fmt.Printf("Invalid --tz: %v\n", err)
+			os.Exit(1)
+		}
+		opts.TZ = location
+	}
+	if *compute != "" {
+		if err := parseCompute(splitList(*compute)); err != nil {
+			fmt.Printf("Invalid --compute: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := parseExtensionMimes(splitList(*extensionMimesFlag)); err != nil {
+		fmt.Printf("Invalid --extension-mimes: %v\n", err)
+		os.Exit(1)
+	}
+	if *chunkSize != "" {
+		size, err := parseSize(*chunkSize)
+		if err != nil || size <= 0 {
+			fmt.Printf("Invalid --chunk-size %q\n", *chunkSize)
+			os.Exit(1)
+		}
+		opts.ChunkSize = size
+	}
+	if *s3PartSize != "" {
+		size, err := parseSize(*s3PartSize)
+		if err != nil || size <= 0 {
+			fmt.Printf("Invalid --s3-etag part size %q\n", *s3PartSize)
+			os.Exit(1)
+		}
+		opts.S3PartSize = size
+	}
+	if *sampleSize != "" {
+		size, err := parseSize(*sampleSize)
+		if err != nil || size <= 0 {
+			fmt.Printf("Invalid --sample-hash size %q\n", *sampleSize)
+			os.Exit(1)
+		}
+		opts.SampleSize = size
+	}
+	if *maxFileSize != "" {
+		size, err := parseSize(*maxFileSize)
+		if err != nil || size <= 0 {
+			fmt.Printf("Invalid --max-file-size %q\n", *maxFileSize)
+			os.Exit(1)
+		}
+		opts.MaxFileSize = size
+	}
+	if opts.HashStream && (opts.DoubleHash || opts.PixelHash || opts.ContentHash || opts.SampleSize > 0) {
+		fmt.Println("--hash-from-stream reads stdin once and can't be combined with --double-hash, --pixel-hash, --content-hash or --sample-hash")
+		os.Exit(1)
+	}
+	if opts.Archive && (opts.Recursive || opts.StdinList || opts.Short || opts.DoubleHash || opts.PixelHash || opts.ContentHash || opts.SampleSize > 0) {
+		fmt.Println("--archive reads each entry once and can't be combined with --recursive, --stdin-list, --short, --double-hash, --pixel-hash, --content-hash or --sample-hash")
+		os.Exit(1)
+	}
+	if opts.FIPS {
+		if opts.S3PartSize > 0 {
+			fmt.Println("Error: --s3-etag is MD5 based and can't be used with --fips")
+			os.Exit(1)
+		}
+		if err := checkFIPSFields(splitList(opts.Fields)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// analyzeFile runs every extraction step on filePath.
+func analyzeFile(filePath string, fileInfo os.FileInfo) (*MediaMetadata, error) {
+	if fileInfo.Size() == 0 {
+		return analyzeEmptyFile(filePath)
+	}
 
-	exif, err := getExifData(filePath)
+	var timings Timings
+	start := time.Now()
+	exif, exifWarnings, err := getExifData(filePath)
 	if err != nil {
-		fmt.Printf("Error reading EXIF: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error reading EXIF: %w", err)
+	}
+	timings.ExifMs = millisSince(start)
+
+	var notes []Note
+	for _, w := range exifWarnings {
+		notes = append(notes, newNote(noteExiftoolWarning, w))
+	}
+
+	var sidecar string
+	if !opts.NoSidecar {
+		if sidecar = findXMPSidecar(filePath); sidecar != "" {
+			if err := mergeXMPSidecar(exif, sidecar); err != nil {
+				notes = append(notes, newNote(noteSidecarError, fmt.Sprintf("XMP sidecar %s: %v", sidecar, err)))
+				sidecar = ""
+			}
+		}
 	}
 
-	media, err := getMediaInfo(filePath)
+	start = time.Now()
+	media, mediaWarning, err := getMediaInfo(filePath)
 	if err != nil {
-		fmt.Printf("Error reading MediaInfo: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error reading MediaInfo: %w", err)
+	}
+	timings.MediaInfoMs = millisSince(start)
+	if mediaWarning != "" {
+		notes = append(notes, newNote(noteMediaInfoNoData, mediaWarning))
 	}
 
 	fileSize := fileInfo.Size()
-	fileSizeHuman := humanReadableSize(fileSize)
-	duration := extractDuration(exif, media)
-	isEnc := isEncrypted(media)
-	mimeType := getMimeType(filePath, exif)
+
+	var digests fileDigests
+	if hashingEnabled(fileSize) {
+		start = time.Now()
+		if digests, err = hashFile(filePath); err != nil {
+			return nil, fmt.Errorf("error computing file hashes: %w", err)
+		}
+		timings.HashingMs = millisSince(start)
+	}
+
+	mimeType, mimeSource, mimeWarning := getMimeType(filePath, exif, digests.head)
+	if (mimeType == "application/gzip" || mimeType == "application/x-gzip") && isTGS(filePath) {
+		mimeType, mimeSource = tgsMime, "sniff"
+	}
+	if mimeWarning != "" {
+		notes = append(notes, newNote(noteMimeMismatch, mimeWarning))
+	}
+	if opts.HeaderOnly {
+		notes = append(notes, newNote(noteHashSkipped, "hashes not computed: header-only mode"))
+	} else if !opts.NoHash && !hashingEnabled(fileSize) {
+		notes = append(notes, newNote(noteHashSkipped, fmt.Sprintf("hashes not computed: file is larger than --max-file-size (%d bytes)", opts.MaxFileSize)))
+	}
 
 	fileExt := "txt"
 	if ext, ok := exif["FileTypeExtension"].(string); ok && ext != "" {
 		fileExt = strings.ToLower(ext)
 	}
 
-	isAnim := isAnimation(mimeType, exif, media)
-	videoAudioOnly := isVideoWithAudioOnly(mimeType, exif, media)
-	hashes, err := computeHashes(filePath)
-	if err != nil {
-		fmt.Printf("Error computing file hashes: %v\n", err)
+	var thumbnail *ThumbnailInfo
+	if opts.ThumbDir != "" {
+		thumbnail, err = extractThumbnail(filePath, opts.ThumbDir, exif)
+		if err != nil {
+			return nil, fmt.Errorf("error extracting thumbnail: %w", err)
+		}
+	}
+
+	var pixel string
+	if opts.PixelHash && strings.HasPrefix(mimeType, "image/") {
+		// formats the image package can't decode just go without
+		pixel, _ = pixelHash(filePath)
+	}
+	var content string
+	if opts.ContentHash {
+		// likewise for formats without a content hash or malformed files
+		content, _ = contentHash(filePath, mimeType)
+	}
+
+	result := &MediaMetadata{
+		FileName:      filePath,
+		MimeType:      mimeType,
+		MimeSource:    mimeSource,
+		FileSize:      fileSize,
+		FileExt:       fileExt,
+		FileSizeHuman: humanReadableSize(fileSize),
+		ExifWarnings:  exifWarnings,
+		XMPSidecar:    sidecar,
+		Notes:         notes,
+		PixelHash:     pixel,
+		ContentHash:   content,
+		EXIF:          exif,
+		Media:         media,
+		Thumbnail:     thumbnail,
+	}
+	if opts.Timings {
+		result.Timings = &timings
+	}
+	digests.apply(result)
+	runExtractors(exif, media, result)
+	return result, nil
+}
+
+func main() {
+	parseFlags()
+
+	if opts.TimeoutTotal > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(context.Background(), opts.TimeoutTotal)
+		defer cancel()
+	}
+
+	if opts.Version {
+		printVersion()
+		return
+	}
+
+	if opts.Doctor {
+		if !runDoctor() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if opts.EnvDump {
+		envJson, err := json.MarshalIndent(currentEnvironment(), "", "  ")
+		if err != nil {
+			fmt.Printf("Failed to marshal environment: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(envJson))
+		return
+	}
+
+	if opts.Schema {
+		schemaJson, err := json.MarshalIndent(outputSchema(), "", "  ")
+		if err != nil {
+			fmt.Printf("Failed to marshal schema: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(schemaJson))
+		return
+	}
+
+	if opts.DryRun {
+		if !opts.Recursive {
+			fmt.Println("--dry-run needs --recursive")
+			os.Exit(1)
+		}
+		if flag.NArg() < 1 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		if runDryRun(flag.Args()) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if opts.Checksum != "" {
+		if flag.NArg() < 1 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		if runChecksum(opts.Checksum, flag.Args()) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := magicmime.Open(magicmime.MAGIC_MIME_TYPE); err != nil {
+		fmt.Printf("Failed to initialize libmagic: %v\n", err)
+		os.Exit(1)
+	}
+	defer magicmime.Close()
+
+	if flag.NArg() < 1 && !opts.StdinList {
+		flag.Usage()
 		os.Exit(1)
 	}
+	if opts.MimeOnly {
+		if runMimeOnly(flag.Args()) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var cache *resultCache
+	if opts.CachePath != "" {
+		var err error
+		cache, err = loadCache(opts.CachePath)
+		if err != nil {
+			fmt.Printf("Error loading cache: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if opts.Diff {
+		if flag.NArg() != 2 {
+			fmt.Println("--diff needs exactly two files")
+			os.Exit(1)
+		}
+		diff, err := diffFiles(flag.Arg(0), flag.Arg(1), cache)
+		if err != nil {
+			fmt.Printf("Error comparing files: %v\n", err)
+			os.Exit(1)
+		}
+		if err := cache.save(); err != nil {
+			fmt.Printf("Error saving cache: %v\n", err)
+			os.Exit(1)
+		}
+		diffJson, err := json.Marshal(diff)
+		if err != nil {
+			fmt.Printf("Failed to marshal result: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(diffJson))
+		return
+	}
 
-	result := MediaMetadata{
-		FileName:                filePath,
-		MimeType:                mimeType,
-		FileSize:                fileSize,
-		FileExt:                 fileExt,
-		FileSizeHuman:           fileSizeHuman,
-		Duration:                duration,
-		MediaIsAnimation:        isAnim,
-		MediaIsEncrypted:        isEnc,
-		MediaVideoWithAudioOnly: videoAudioOnly,
-		Hashes:                  hashes,
-		EXIF:                    exif,
-		Media:                   media,
+	if opts.Archive {
+		if runArchives(flag.Args()) {
+			os.Exit(1)
+		}
+		return
 	}
 
-	resultJson, err := json.Marshal(result)
+	if opts.Recursive || opts.StdinList {
+		var failed bool
+		if opts.StdinList {
+			failed = runStdinList(os.Stdin, cache)
+		} else {
+			failed = runRecursive(flag.Args(), cache)
+		}
+		if err := cache.save(); err != nil {
+			fmt.Printf("Error saving cache: %v\n", err)
+			os.Exit(1)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	filePath := flag.Arg(0)
+	var result *MediaMetadata
+	var err error
+	switch {
+	case filePath == stdinPath && opts.HashStream:
+		result, err = analyzeStream(os.Stdin)
+	case filePath == stdinPath:
+		result, err = analyzeStdin()
+	default:
+		result, err = safeAnalyzePath(filePath, cache)
+	}
 	if err != nil {
+		fmt.Printf("Error analyzing %s: %v\n", filePath, err)
+		os.Exit(1)
+	}
+	if err := cache.save(); err != nil {
+		fmt.Printf("Error saving cache: %v\n", err)
+		os.Exit(1)
+	}
+
+	stdout := bufio.NewWriter(os.Stdout)
+	if err := writeResult(stdout, result); err != nil {
 		fmt.Printf("Failed to marshal result: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println(string(resultJson))
+	stdout.Flush()
+	if message, rejected := rejectedMismatch(result); rejected {
+		fmt.Fprintf(os.Stderr, "Rejected %s: %s\n", filePath, message)
+		os.Exit(1)
+	}
+}
+
+// emptyMime is what libmagic reports for a zero-length file.
+const emptyMime = "inode/x-empty"
+
+// analyzeEmptyFile describes a zero-length file without running exiftool
+// or mediainfo, which both fail on one.
+func analyzeEmptyFile(filePath string) (*MediaMetadata, error) {
+	result := &MediaMetadata{
+		FileName:      filePath,
+		MimeType:      emptyMime,
+		FileExt:       strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), ".")),
+		FileSizeHuman: humanReadableSize(0),
+		Notes:         []Note{newNote(noteEmptyFile, "empty file: exiftool and mediainfo were not run")},
+		EXIF:          map[string]interface{}{},
+		Media:         map[string]interface{}{},
+	}
+	if hashingEnabled(0) {
+		digests, err := hashFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("error computing file hashes: %w", err)
+		}
+		digests.apply(result)
+	}
+	runExtractors(result.EXIF, result.Media, result)
+	return result, nil
+}
+
+// analyzePath stats and analyzes filePath, serving the result from cache
+// when it is still valid.
+func analyzePath(filePath string, cache *resultCache) (*MediaMetadata, error) {
+	start := time.Now()
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error getting file size: %w", err)
+	}
+	if fileInfo.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", filePath)
+	}
+	// a pipe, device or socket would block the tools, or checkReadable
+	if mode := fileInfo.Mode(); !mode.IsRegular() {
+		if mode&os.ModeNamedPipe != 0 && opts.AllowFIFO {
+			return analyzeFIFO(filePath)
+		}
+		return nil, &notRegularError{path: filePath, kind: irregularKind(mode)}
+	}
+	// fail unreadable files up front rather than with whatever exiftool
+	// makes of them
+	if err := checkReadable(filePath); err != nil {
+		return nil, err
+	}
+	result, ok := cache.lookup(filePath, fileInfo)
+	if !ok {
+		if result, err = analyzeFile(filePath, fileInfo); err != nil {
+			return nil, err
+		}
+		cache.store(filePath, fileInfo, result)
+	}
+	if opts.Strict {
+		if err := strictError(result.Notes); err != nil {
+			return nil, err
+		}
+	}
+	// ownership and permissions can change without touching the mtime
+	// the cache is keyed on, so they're always read fresh
+	result.FS = fileSystemInfo(fileInfo)
+	// the symlink a file was reached through is up to the caller, and
+	// belongs to the run that set it
+	result.ResolvedPath = ""
+	// the trailing data scan is opt-in, so it isn't part of what's cached
+	result.TrailingData = nil
+	if opts.TrailingData {
+		result.TrailingData = detectTrailingData(filePath, result.MimeType, fileInfo.Size())
+	}
+	// sampling reads little enough that it isn't worth caching
+	result.SampleFingerprint = ""
+	if opts.SampleSize > 0 {
+		if result.SampleFingerprint, err = sampleFingerprint(filePath, opts.SampleSize); err != nil {
+			return nil, fmt.Errorf("error computing sample fingerprint: %w", err)
+		}
+	}
+	// .nfo files are edited independently of the media they describe
+	result.NFO = nil
+	result.Notes = withoutNote(result.Notes, noteNFOError)
+	if opts.NFO {
+		if path := findNFO(filePath); path != "" {
+			if result.NFO, err = readNFO(path); err != nil {
+				result.Notes = append(result.Notes, newNote(noteNFOError, fmt.Sprintf("NFO %s: %v", path, err)))
+			}
+		}
+	}
+	// embedded media is re-extracted each run rather than cached
+	result.Embedded = nil
+	result.Notes = withoutNote(result.Notes, noteEmbeddedError)
+	if opts.FollowEmbeds {
+		var notes []Note
+		result.Embedded, notes = extractEmbedded(filePath, filePath, result.EXIF, 0)
+		result.Notes = append(result.Notes, notes...)
+	}
+	checkExtension(result, filePath)
+	// timings describe this run, not the one that filled the cache
+	if !opts.Timings {
+		result.Timings = nil
+	} else {
+		if ok || result.Timings == nil {
+			result.Timings = &Timings{Cached: ok}
+		}
+		result.Timings.TotalMs = millisSince(start)
+	}
+	return result, nil
+}
+
+func checkReadable(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+	return f.Close()
+}
+
+// relativePath returns path relative to --base, or path unchanged if
+// there's no relative path between them, as on different Windows drives.
+func relativePath(path string) string {
+	if opts.Base == "" || path == stdinPath {
+		return path
+	}
+	base, err := filepath.Abs(opts.Base)
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(base, abs); err == nil {
+		return rel
+	}
+	return path
+}
+
+// renderResult applies the output options to result and marshals it.
+func renderResult(result *MediaMetadata) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeResult(&buf, result); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// writeResult applies the output options to result and writes it to w as
+// one line.
+func writeResult(w io.Writer, result *MediaMetadata) error {
+	output, err := outputDocument(result)
+	if err != nil {
+		return err
+	}
+	if line, ok := output.(string); ok {
+		_, err := io.WriteString(w, line+"\n")
+		return err
+	}
+	return encodeJSON(w, output)
+}
+
+// transformMaps applies the options that rewrite the raw exif and media
+// maps (--redact, --max-exif-value-len and --normalize-keys) to result and
+// the media embedded in it.
+func transformMaps(result *MediaMetadata) *MediaMetadata {
+	if opts.Redact || opts.RedactKeys != "" {
+		keys := defaultRedactKeys
+		if opts.RedactKeys != "" {
+			keys = splitList(opts.RedactKeys)
+		}
+		// result may be shared with the cache, which keeps the full data
+		redacted := *result
+		result = &redacted
+		result.EXIF = maps.Clone(result.EXIF)
+		if result.Tags != nil {
+			tags := *result.Tags
+			result.Tags = &tags
+		}
+		redactMap(result.EXIF, keys)
+		mediaKeys := keys
+		// the gps object is derived from the GPS* tags, so it goes with
+		// them, as does the location mediainfo reports
+		if redacts(keys, "GPSLatitude") {
+			result.GPS = nil
+			mediaKeys = append(slices.Clip(keys), mediaLocationKeys...)
+		}
+		result.Media = redactMedia(result.Media, mediaKeys)
+		if result.Tags != nil && redacts(keys, "Artist") {
+			result.Tags.Artist = ""
+		}
+	}
+
+	if opts.MaxExifLen > 0 {
+		truncated := *result
+		result = &truncated
+		result.EXIF = maps.Clone(result.EXIF)
+		result.ExifTruncated = truncateExif(result.EXIF, opts.MaxExifLen)
+	}
+
+	if opts.NormalizeKeys {
+		normalized := *result
+		result = &normalized
+		result.EXIF, result.Media = normalizeKeys(result.EXIF), normalizeKeys(result.Media)
+	}
+
+	if len(result.Embedded) > 0 && (opts.Redact || opts.RedactKeys != "" || opts.MaxExifLen > 0 || opts.NormalizeKeys) {
+		embedded := make([]EmbeddedMedia, len(result.Embedded))
+		for i, e := range result.Embedded {
+			embedded[i] = EmbeddedMedia{Source: e.Source, Metadata: transformMaps(e.Metadata)}
+		}
+		transformed := *result
+		result = &transformed
+		result.Embedded = embedded
+	}
+	return result
+}
+
+// outputDocument applies the output options to result, returning what
+// gets encoded, or the line itself for --short.
+func outputDocument(result *MediaMetadata) (interface{}, error) {
+	result = transformMaps(result)
+
+	if opts.Base != "" {
+		relative := *result
+		result = &relative
+		result.FileName = relativePath(result.FileName)
+	}
+
+	if opts.EmbedEnv {
+		embedded := *result
+		result = &embedded
+		result.Environment = currentEnvironment()
+	}
+
+	if opts.Short {
+		return shortLine(result), nil
+	}
+
+	var output interface{} = result
+	if opts.Summary {
+		output = newSummary(result)
+	}
+	if opts.Fields != "" {
+		doc, err := toDocument(output)
+		if err != nil {
+			return nil, err
+		}
+		projected, unknown := projectFields(doc, splitList(opts.Fields))
+		for _, path := range unknown {
+			fmt.Fprintf(os.Stderr, "Warning: unknown field %q\n", path)
+		}
+		output = projected
+	}
+	if opts.Grouped {
+		doc, err := toDocument(output)
+		if err != nil {
+			return nil, err
+		}
+		output = groupDocument(doc)
+	}
+	if opts.KeyStyle == "camel" {
+		doc, err := toDocument(output)
+		if err != nil {
+			return nil, err
+		}
+		output = camelDocument(doc)
+	}
+	return output, nil
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/rakyll/magicmime"
+)
+
+// TestMain opens libmagic, as main does, for the tests that detect MIME
+// types.
+func TestMain(m *testing.M) {
+	if err := magicmime.Open(magicmime.MAGIC_MIME_TYPE); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing libmagic: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	magicmime.Close()
+	os.Exit(code)
+}
+
+// stubTool replaces exiftool or mediainfo with a shell script for the
+// rest of the test.
+func stubTool(t *testing.T, tool, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("stub tools are shell scripts")
+	}
+	path := filepath.Join(t.TempDir(), tool)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	field := &opts.ExiftoolPath
+	if tool == "mediainfo" {
+		field = &opts.MediainfoPath
+	}
+	old := *field
+	*field = path
+	t.Cleanup(func() { *field = old })
+}
+
+// saveOpts restores opts once the test is done with it.
+func saveOpts(t *testing.T) {
+	saved := opts
+	t.Cleanup(func() { opts = saved })
+}
+
+func TestFileArg(t *testing.T) {
+	sep := string(filepath.Separator)
+	tests := []struct {
+		path, want string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"-rf.jpg", "." + sep + "-rf.jpg"},
+		{"--help", "." + sep + "--help"},
+		{"-", "." + sep + "-"},
+		{"a-b.jpg", "a-b.jpg"},
+		{"dir/-x.jpg", "dir/-x.jpg"},
+		{"/tmp/-x.jpg", "/tmp/-x.jpg"},
+		{"new\nline.jpg", "new\nline.jpg"},
+		{"ünïcödé 写真.jpg", "ünïcödé 写真.jpg"},
+	}
+	for _, tt := range tests {
+		if got := fileArg(tt.path); got != tt.want {
+			t.Errorf("fileArg(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestFileNameRoundTrip analyzes files with awkward names and checks the
+// tools are handed a path they can't take for an option and that the
+// name comes back unchanged in the JSON output.
+func TestFileNameRoundTrip(t *testing.T) {
+	saveOpts(t)
+	opts.NoHash = true
+	stubTool(t, "exiftool", `for f; do :; done
+case "$f" in -*) echo "unknown option $f" >&2; exit 1;; esac
+[ -f "$f" ] || exit 1
+echo '[{"MIMEType":"text/plain","FileTypeExtension":"txt"}]'
+`)
+	stubTool(t, "mediainfo", `for f; do :; done
+case "$f" in -*) echo "unknown option $f" >&2; exit 1;; esac
+echo '{"media":null}'
+`)
+	t.Chdir(t.TempDir())
+
+	for _, name := range []string{
+		"-leading.txt",
+		"--help.txt",
+		"new\nline.txt",
+		"tab\there.txt",
+		"ünïcödé 写真.txt",
+		`quote"and\backslash.txt`,
+	} {
+		if err := os.WriteFile(name, []byte("hello\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		result, err := analyzePath(name, nil)
+		if err != nil {
+			t.Errorf("analyzePath(%q): %v", name, err)
+			continue
+		}
+		if result.FileName != name {
+			t.Errorf("FileName = %q, want %q", result.FileName, name)
+		}
+
+		var buf bytes.Buffer
+		if err := writeResult(&buf, result); err != nil {
+			t.Fatalf("writeResult(%q): %v", name, err)
+		}
+		var doc struct {
+			FileName string `json:"file_name"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("output for %q isn't JSON: %v", name, err)
+		}
+		if doc.FileName != name {
+			t.Errorf("file_name = %q, want %q", doc.FileName, name)
+		}
+	}
+}
+
+// TestMediaInfoNoData covers mediainfo printing nothing or plain text
+// instead of JSON, as it does for testdata/plain.txt: the file is still
+// analyzed, with a MEDIAINFO_NO_DATA note.
+func TestMediaInfoNoData(t *testing.T) {
+	const fixture = "testdata/plain.txt"
+	tests := []struct {
+		name, script, warning string
+	}{
+		{"empty", "exit 0\n", "mediainfo printed no output"},
+		{"text", "echo 'General'\necho 'Complete name : plain.txt'\n", "mediainfo output isn't JSON: General"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saveOpts(t)
+			opts.NoHash = true
+			stubTool(t, "exiftool", `echo '[{"MIMEType":"text/plain","FileTypeExtension":"txt"}]'`+"\n")
+			stubTool(t, "mediainfo", tt.script)
+
+			media, warning, err := getMediaInfo(fixture)
+			if err != nil {
+				t.Fatalf("getMediaInfo: %v", err)
+			}
+			if len(media) != 0 || warning != tt.warning {
+				t.Errorf("getMediaInfo = %v, %q, want an empty map and %q", media, warning, tt.warning)
+			}
+
+			result, err := analyzePath(fixture, nil)
+			if err != nil {
+				t.Fatalf("analyzePath: %v", err)
+			}
+			if len(result.Media) != 0 {
+				t.Errorf("media = %v, want an empty map", result.Media)
+			}
+			var found bool
+			for _, n := range result.Notes {
+				found = found || n.Code == noteMediaInfoNoData && n.Message == tt.warning
+			}
+			if !found {
+				t.Errorf("notes = %v, want %s: %s", result.Notes, noteMediaInfoNoData, tt.warning)
+			}
+		})
+	}
+}
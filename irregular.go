@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// notRegularError is returned for a named pipe, device or socket, which
+// has no meaningful size and on which exiftool and mediainfo would block.
+type notRegularError struct {
+	path string
+	kind string
+}
+
+func (e *notRegularError) Error() string {
+	return fmt.Sprintf("skipped %s: it is a %s, not a regular file", e.path, e.kind)
+}
+
+// isIrregular reports whether mode is a named pipe, device or socket.
+func isIrregular(mode fs.FileMode) bool {
+	return mode&(fs.ModeNamedPipe|fs.ModeDevice|fs.ModeSocket) != 0
+}
+
+func irregularKind(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&fs.ModeCharDevice != 0:
+		return "character device"
+	case mode&fs.ModeDevice != 0:
+		return "block device"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	}
+	return "special file"
+}
+
+// analyzeFIFO reads a named pipe through once, as --hash-from-stream does
+// stdin, for --allow-fifo. Opening it waits for a writer.
+func analyzeFIFO(filePath string) (*MediaMetadata, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+	result, err := analyzeReader(f, filePath, 0, "read from a named pipe: exiftool and mediainfo were not run")
+	if err != nil {
+		return nil, err
+	}
+	result.FileName = filePath
+	return result, nil
+}
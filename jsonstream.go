@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"slices"
+)
+
+// encodeJSON writes v to w as a single line of JSON, byte for byte what
+// json.Marshal produces followed by a newline. A *MediaMetadata is written
+// one field at a time, and its raw exif and media maps one key at a time,
+// so a file with enormous maps is never held in memory as one encoded
+// document.
+func encodeJSON(w io.Writer, v interface{}) error {
+	result, ok := v.(*MediaMetadata)
+	if !ok || result == nil {
+		return json.NewEncoder(w).Encode(v)
+	}
+
+	value := reflect.ValueOf(result).Elem()
+	first := true
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		name, omitEmpty := jsonFieldName(structField)
+		if name == "-" {
+			continue
+		}
+		field := value.Field(i)
+		if omitEmpty && isEmptyValue(field) {
+			continue
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		if err := writeJSONValue(w, name); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, ":"); err != nil {
+			return err
+		}
+		if raw, ok := field.Interface().(map[string]interface{}); ok && raw != nil {
+			if err := encodeMap(w, raw); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeJSONValue(w, field.Interface()); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// encodeMap writes m with its keys in the sorted order encoding/json uses,
+// marshaling one value at a time.
+func encodeMap(w io.Writer, m map[string]interface{}) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := writeJSONValue(w, key); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, ":"); err != nil {
+			return err
+		}
+		if err := writeJSONValue(w, m[key]); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+func writeJSONValue(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// isEmptyValue reports whether omitempty leaves v out, following the
+// rules of encoding/json rather than reflect's IsZero: empty slices and
+// maps are left out too, structs never are.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// rawMaps are the output keys holding exiftool's and mediainfo's own
+// data. Their keys come from the tools and are never restyled.
+var rawMaps = map[string]bool{"exif": true, "media": true}
+
+// camelCase turns a snake_case key into camelCase.
+func camelCase(key string) string {
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// camelDocument renames the keys of doc and of every object nested in it
+// to camelCase, leaving the raw tool maps alone.
+func camelDocument(doc map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(doc))
+	for key, value := range doc {
+		if !rawMaps[key] {
+			value = camelValue(value)
+		}
+		out[camelCase(key)] = value
+	}
+	return out
+}
+
+func camelValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return camelDocument(v)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = camelValue(item)
+		}
+	}
+	return value
+}
+
+// camelSchema applies the same renaming to the property names of an
+// object schema and its nested object and array schemas.
+func camelSchema(schema map[string]interface{}) map[string]interface{} {
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		renamed := make(map[string]interface{}, len(properties))
+		for name, property := range properties {
+			if sub, ok := property.(map[string]interface{}); ok && !rawMaps[name] {
+				property = camelSchema(sub)
+			}
+			renamed[camelCase(name)] = property
+		}
+		schema["properties"] = renamed
+	}
+	if required, ok := schema["required"].([]string); ok {
+		for i, name := range required {
+			required[i] = camelCase(name)
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		schema["items"] = camelSchema(items)
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		schema["additionalProperties"] = camelSchema(additional)
+	}
+	return schema
+}
+
+// snakeCase turns a tool's key into lowercase snake_case: "MIMEType" and
+// "MIME Type" both become "mime_type", "Format_Profile" becomes
+// "format_profile". A run of capitals is kept together as an acronym.
+func snakeCase(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	for i, r := range runes {
+		if r == ' ' || r == '-' || r == '_' {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 && !strings.HasSuffix(b.String(), "_") {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// normalizeKeys returns a copy of a raw tool map with every key, in
+// nested objects too, in snakeCase, for --normalize-keys. Where two keys
+// normalize alike the one sorting first wins, so the result doesn't
+// depend on map order.
+func normalizeKeys(raw map[string]interface{}) map[string]interface{} {
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make(map[string]interface{}, len(raw))
+	for _, key := range keys {
+		normalized := snakeCase(key)
+		if _, taken := out[normalized]; !taken {
+			out[normalized] = normalizeValue(raw[key])
+		}
+	}
+	return out
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return normalizeKeys(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalizeValue(item)
+		}
+		return items
+	}
+	return value
+}
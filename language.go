@@ -0,0 +1,81 @@
+package main
+
+import "strings"
+
+// languages lists the ISO 639-1 code, the ISO 639-2 codes (bibliographic
+// first where it differs) and the English name of languages commonly
+// found in media files.
+var languages = []struct {
+	code  string
+	alpha []string
+	name  string
+}{
+	{"ar", []string{"ara"}, "Arabic"},
+	{"bg", []string{"bul"}, "Bulgarian"},
+	{"bn", []string{"ben"}, "Bengali"},
+	{"ca", []string{"cat"}, "Catalan"},
+	{"cs", []string{"cze", "ces"}, "Czech"},
+	{"da", []string{"dan"}, "Danish"},
+	{"de", []string{"ger", "deu"}, "German"},
+	{"el", []string{"gre", "ell"}, "Greek"},
+	{"en", []string{"eng"}, "English"},
+	{"es", []string{"spa"}, "Spanish"},
+	{"et", []string{"est"}, "Estonian"},
+	{"fa", []string{"per", "fas"}, "Persian"},
+	{"fi", []string{"fin"}, "Finnish"},
+	{"fr", []string{"fre", "fra"}, "French"},
+	{"he", []string{"heb"}, "Hebrew"},
+	{"hi", []string{"hin"}, "Hindi"},
+	{"hr", []string{"hrv"}, "Croatian"},
+	{"hu", []string{"hun"}, "Hungarian"},
+	{"id", []string{"ind"}, "Indonesian"},
+	{"is", []string{"ice", "isl"}, "Icelandic"},
+	{"it", []string{"ita"}, "Italian"},
+	{"ja", []string{"jpn"}, "Japanese"},
+	{"ko", []string{"kor"}, "Korean"},
+	{"lt", []string{"lit"}, "Lithuanian"},
+	{"lv", []string{"lav"}, "Latvian"},
+	{"ms", []string{"may", "msa"}, "Malay"},
+	{"nl", []string{"dut", "nld"}, "Dutch"},
+	{"no", []string{"nor"}, "Norwegian"},
+	{"pl", []string{"pol"}, "Polish"},
+	{"pt", []string{"por"}, "Portuguese"},
+	{"ro", []string{"rum", "ron"}, "Romanian"},
+	{"ru", []string{"rus"}, "Russian"},
+	{"sk", []string{"slo", "slk"}, "Slovak"},
+	{"sl", []string{"slv"}, "Slovenian"},
+	{"sr", []string{"srp"}, "Serbian"},
+	{"sv", []string{"swe"}, "Swedish"},
+	{"ta", []string{"tam"}, "Tamil"},
+	{"th", []string{"tha"}, "Thai"},
+	{"tr", []string{"tur"}, "Turkish"},
+	{"uk", []string{"ukr"}, "Ukrainian"},
+	{"vi", []string{"vie"}, "Vietnamese"},
+	{"zh", []string{"chi", "zho"}, "Chinese"},
+}
+
+// normalizeLanguage maps a language as encoders write it ("en", "eng",
+// "en-US", "English") to its ISO 639-1 code and English name. Unknown
+// values are returned unchanged with no name.
+func normalizeLanguage(value string) (string, string) {
+	raw := strings.TrimSpace(value)
+	if raw == "" {
+		return "", ""
+	}
+	key := strings.ReplaceAll(strings.ToLower(raw), "_", "-")
+	// region and script subtags don't change the language
+	if base, _, ok := strings.Cut(key, "-"); ok && len(base) <= 3 {
+		key = base
+	}
+	for _, lang := range languages {
+		if key == lang.code || key == strings.ToLower(lang.name) {
+			return lang.code, lang.name
+		}
+		for _, alpha := range lang.alpha {
+			if key == alpha {
+				return lang.code, lang.name
+			}
+		}
+	}
+	return raw, ""
+}
@@ -0,0 +1,14 @@
+package main
+
+// #cgo LDFLAGS: -lmagic
+// #include <magic.h>
+import "C"
+
+import "fmt"
+
+// libmagicVersion returns the version of the libmagic infx is linked
+// against, e.g. "5.45". magic_version reports it as major*100+minor.
+func libmagicVersion() string {
+	v := int(C.magic_version())
+	return fmt.Sprintf("%d.%02d", v/100, v%100)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// extensionMimes lists the MIME types, by their canonicalMime name, a
+// file with each extension is expected to have. extensionMismatch
+// accepts them, and checks the extension against them when exiftool
+// doesn't identify the file. --extension-mimes replaces the entries for
+// the extensions it names.
+var extensionMimes = map[string][]string{
+	"jpg":  {"image/jpeg"},
+	"png":  {"image/png"},
+	"gif":  {"image/gif"},
+	"webp": {"image/webp"},
+	"heic": {"image/heic", "image/heif"},
+	"avif": {"image/avif"},
+	"tif":  {"image/tiff"},
+	"bmp":  {"image/bmp"},
+	"svg":  {"image/svg+xml"},
+	"mp4":  {"video/mp4"},
+	"mov":  {"video/quicktime"},
+	"mkv":  {"video/x-matroska"},
+	"webm": {"video/webm"},
+	"avi":  {"video/x-msvideo"},
+	"mp3":  {"audio/mpeg"},
+	"m4a":  {"audio/mp4"},
+	"flac": {"audio/flac"},
+	"wav":  {"audio/wav"},
+	"ogg":  {"audio/ogg", "video/ogg", "application/ogg"},
+	"opus": {"audio/ogg", "audio/opus"},
+	"pdf":  {"application/pdf"},
+	"zip":  {"application/zip"},
+	"gz":   {"application/gzip"},
+	"txt":  {"text/plain"},
+	"json": {"application/json", "text/plain"},
+}
+
+// parseExtensionMimes applies --extension-mimes entries of the form
+// ext=mime|mime to extensionMimes.
+func parseExtensionMimes(entries []string) error {
+	for _, entry := range entries {
+		ext, mimes, ok := strings.Cut(entry, "=")
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if !ok || ext == "" || strings.TrimSpace(mimes) == "" {
+			return fmt.Errorf("%q is not ext=mime", entry)
+		}
+		var list []string
+		for _, mime := range strings.Split(mimes, "|") {
+			if mime = strings.TrimSpace(mime); mime != "" {
+				list = append(list, canonicalMime(mime))
+			}
+		}
+		if alias, ok := extensionAliases[ext]; ok {
+			ext = alias
+		}
+		extensionMimes[ext] = list
+	}
+	return nil
+}
+
+// expectedMime reports whether extensionMimes lists mimeType for ext.
+func expectedMime(ext, mimeType string) bool {
+	return slices.Contains(extensionMimes[ext], canonicalMime(mimeType))
+}
+
+// checkExtension replaces any EXT_MISMATCH note of result with one for
+// name. It's redone on every run since the accepted types are
+// configurable and a cached result may have been checked with others.
+func checkExtension(result *MediaMetadata, name string) {
+	result.Notes = withoutNote(result.Notes, noteExtMismatch)
+	if mismatch := extensionMismatch(name, result.EXIF, result.MimeType); mismatch != "" {
+		result.Notes = append(result.Notes, newNote(noteExtMismatch, mismatch))
+	}
+}
+
+// rejectedMismatch reports whether --fail-on-mismatch fails result,
+// returning the mismatch message.
+func rejectedMismatch(result *MediaMetadata) (string, bool) {
+	if !opts.FailMismatch {
+		return "", false
+	}
+	for _, n := range result.Notes {
+		if n.Code == noteExtMismatch {
+			return n.Message, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runMimeOnly prints a MimeResult for each of paths, or an errorRecord for
+// those that can't be read. It reports whether any failed.
+func runMimeOnly(paths []string) bool {
+	failed := false
+	for _, path := range paths {
+		var line []byte
+		result, err := mimeOnly(path)
+		if err == nil {
+			line, err = json.Marshal(result)
+		}
+		if err != nil {
+			failed = true
+			line, err = json.Marshal(errorRecord{FileName: relativePath(path), Error: err.Error(), Notes: errorNotes(err)})
+			if err != nil {
+				line, _ = json.Marshal(errorRecord{Error: err.Error()})
+			}
+		}
+		fmt.Println(string(line))
+	}
+	return failed
+}
+
+// mimeOnly detects the MIME type of path from its header alone. The file
+// is checked first, since libmagic and the sniffer both just give up on
+// one they can't read.
+func mimeOnly(path string) (MimeResult, error) {
+	var head []byte
+	if path == stdinPath {
+		// only the head is needed, so stdin isn't buffered
+		head = make([]byte, sniffLen)
+		n, err := io.ReadFull(os.Stdin, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return MimeResult{}, fmt.Errorf("error reading stdin: %w", err)
+		}
+		mimeType, mimeSource, _ := getMimeType("", nil, head[:n])
+		return MimeResult{FileName: stdinPath, MimeType: mimeType, MimeSource: mimeSource}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return MimeResult{}, fmt.Errorf("error getting file size: %w", err)
+	}
+	if info.IsDir() {
+		return MimeResult{}, fmt.Errorf("%s is a directory", path)
+	}
+	if mode := info.Mode(); !mode.IsRegular() {
+		return MimeResult{}, &notRegularError{path: path, kind: irregularKind(mode)}
+	}
+	if head, err = readHead(path, sniffLen); err != nil {
+		return MimeResult{}, fmt.Errorf("error opening file: %w", err)
+	}
+	mimeType, mimeSource, _ := getMimeType(path, nil, head)
+	return MimeResult{FileName: relativePath(path), MimeType: mimeType, MimeSource: mimeSource}, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import "strings"
+
+// motionPhotoKeys are tags written by cameras that embed a short video in
+// a still image: Google's MotionPhoto/MicroVideo XMP, Samsung's embedded
+// video trailer and Apple's Live Photo maker note.
+var motionPhotoKeys = []string{
+	"MotionPhoto",
+	"MotionPhotoVersion",
+	"MicroVideo",
+	"MicroVideoOffset",
+	"EmbeddedVideoType",
+	"EmbeddedVideoFile",
+	"MotionPhotoVideo",
+	"LivePhotoVideoIndex",
+}
+
+// detectMotionPhoto reports whether an image carries an embedded video,
+// and that video's duration when mediainfo found its track.
+func detectMotionPhoto(mimeType string, exif, media map[string]interface{}) (bool, string) {
+	if !strings.HasPrefix(mimeType, "image/") {
+		return false, ""
+	}
+
+	found := false
+	for _, key := range motionPhotoKeys {
+		switch v := exif[key].(type) {
+		case nil:
+			continue
+		case float64:
+			found = v != 0
+		case string:
+			found = v != "" && v != "0" && !strings.EqualFold(v, "No") && !strings.EqualFold(v, "False")
+		default:
+			found = true
+		}
+		if found {
+			break
+		}
+	}
+
+	var duration string
+	for _, track := range mediaTracks(media) {
+		if track["@type"] == "Video" {
+			found = true
+			duration, _ = track["Duration"].(string)
+			break
+		}
+	}
+	return found, duration
+}
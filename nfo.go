@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// NFOInfo is the curated metadata a media server (Jellyfin, Kodi, Emby)
+// keeps in an .nfo file next to a video.
+type NFOInfo struct {
+	File   string   `json:"file"`
+	Title  string   `json:"title,omitempty"`
+	Plot   string   `json:"plot,omitempty"`
+	Year   int      `json:"year,omitempty"`
+	Genres []string `json:"genres,omitempty"`
+}
+
+// nfoDocument matches the root element of any NFO flavour (movie,
+// tvshow, episodedetails, musicvideo), since they share these elements.
+type nfoDocument struct {
+	Title     string   `xml:"title"`
+	Plot      string   `xml:"plot"`
+	Year      string   `xml:"year"`
+	Premiered string   `xml:"premiered"`
+	Genres    []string `xml:"genre"`
+}
+
+// findNFO returns the .nfo belonging to filePath: "movie.nfo" next to
+// "movie.mkv", or failing that the "movie.nfo" media servers use for a
+// folder holding a single film.
+func findNFO(filePath string) string {
+	base := strings.TrimSuffix(filePath, filepath.Ext(filePath))
+	candidates := []string{
+		base + ".nfo", base + ".NFO",
+		filepath.Join(filepath.Dir(filePath), "movie.nfo"),
+	}
+	for _, candidate := range candidates {
+		if candidate == filePath {
+			continue
+		}
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// readNFO parses the NFO at path. Only the first XML element is read, as
+// Kodi allows a scraper URL after it.
+func readNFO(path string) (*NFOInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var doc nfoDocument
+	if err := xml.NewDecoder(f).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	info := &NFOInfo{
+		File:  path,
+		Title: strings.TrimSpace(doc.Title),
+		Plot:  strings.TrimSpace(doc.Plot),
+	}
+	if year := yearPattern.FindString(strings.TrimSpace(doc.Year)); year != "" {
+		info.Year, _ = strconv.Atoi(year)
+	} else if year := yearPattern.FindString(strings.TrimSpace(doc.Premiered)); year != "" {
+		info.Year, _ = strconv.Atoi(year)
+	}
+	for _, genre := range doc.Genres {
+		// some scrapers put several genres into one element
+		for _, g := range strings.Split(genre, " / ") {
+			if g = strings.TrimSpace(g); g != "" {
+				info.Genres = append(info.Genres, g)
+			}
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Note is a condition found while analyzing a file. Code is stable and
+// meant for automation; Message is for people and may change.
+type Note struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+const (
+	noteMimeMismatch         = "MIME_MISMATCH"
+	noteExtMismatch          = "EXT_MISMATCH"
+	noteHashSkipped          = "HASH_SKIPPED"
+	noteEmptyFile            = "EMPTY_FILE"
+	noteSidecarError         = "SIDECAR_ERROR"
+	noteExiftoolWarning      = "EXIFTOOL_WARNING"
+	noteTruncated            = "TRUNCATED"
+	noteEncrypted            = "ENCRYPTED"
+	noteExecutableAttachment = "EXECUTABLE_ATTACHMENT"
+	noteNFOError             = "NFO_ERROR"
+	noteMediaInfoNoData      = "MEDIAINFO_NO_DATA"
+	noteReadMismatch         = "READ_MISMATCH"
+	noteStreamOnly           = "STREAM_ONLY"
+	notePermissionDenied     = "PERMISSION_DENIED"
+	noteNotFound             = "NOT_FOUND"
+	noteInternalPanic        = "INTERNAL_PANIC"
+	noteNotRegularFile       = "NOT_REGULAR_FILE"
+	noteEmbeddedError        = "EMBEDDED_ERROR"
+)
+
+// noteSeverities fixes the severity of each code, so the same condition
+// is always reported the same way.
+var noteSeverities = map[string]string{
+	noteMimeMismatch:         "warning",
+	noteExtMismatch:          "warning",
+	noteHashSkipped:          "info",
+	noteEmptyFile:            "info",
+	noteSidecarError:         "warning",
+	noteExiftoolWarning:      "warning",
+	noteTruncated:            "warning",
+	noteEncrypted:            "info",
+	noteExecutableAttachment: "warning",
+	noteNFOError:             "warning",
+	noteMediaInfoNoData:      "info",
+	noteReadMismatch:         "error",
+	noteStreamOnly:           "info",
+	notePermissionDenied:     "error",
+	noteNotFound:             "error",
+	noteInternalPanic:        "error",
+	noteNotRegularFile:       "info",
+	noteEmbeddedError:        "warning",
+}
+
+func newNote(code, message string) Note {
+	return Note{Code: code, Severity: noteSeverities[code], Message: message}
+}
+
+// withoutNote returns notes without those of code. It builds a new slice
+// rather than filtering in place, since notes may be shared with the
+// cache.
+func withoutNote(notes []Note, code string) []Note {
+	var kept []Note
+	for _, n := range notes {
+		if n.Code != code {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+// errorNotes returns a note classifying err if a file couldn't be
+// analyzed because it is unreadable or gone, which is common on a crawl
+// of a live library, because it isn't a regular file, or because
+// analyzing it panicked.
+func errorNotes(err error) []Note {
+	var panicked *panicError
+	var notRegular *notRegularError
+	switch {
+	case errors.As(err, &panicked):
+		return []Note{newNote(noteInternalPanic, "analysis crashed on this file; rerun with --verbose for the stack")}
+	case errors.As(err, &notRegular):
+		message := notRegular.kind + " skipped"
+		if notRegular.kind == "named pipe" {
+			message += "; use --allow-fifo to read it"
+		}
+		return []Note{newNote(noteNotRegularFile, message)}
+	case errors.Is(err, fs.ErrPermission):
+		return []Note{newNote(notePermissionDenied, "not readable by this user")}
+	case errors.Is(err, fs.ErrNotExist):
+		return []Note{newNote(noteNotFound, "does not exist, or was removed during the run")}
+	}
+	return nil
+}
+
+// hasWarnings reports whether any note is more serious than info.
+func hasWarnings(notes []Note) bool {
+	for _, n := range notes {
+		if n.Severity != "info" {
+			return true
+		}
+	}
+	return false
+}
+
+// toolNotes are the codes that carry a warning from exiftool or
+// mediainfo, which --strict turns into errors.
+var toolNotes = map[string]bool{
+	noteExiftoolWarning: true,
+	noteMediaInfoNoData: true,
+}
+
+// strictError returns the error --strict makes of the tool warnings among
+// notes, or nil if there are none.
+func strictError(notes []Note) error {
+	var messages []string
+	for _, n := range notes {
+		if toolNotes[n.Code] {
+			messages = append(messages, n.Message)
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return errors.New("rejected by --strict: " + strings.Join(messages, "; "))
+}
+
+// extensionAliases maps extensions to the one exiftool reports for the
+// same file type.
+var extensionAliases = map[string]string{
+	"jpeg": "jpg", "jpe": "jpg", "tiff": "tif", "htm": "html",
+	"mpeg": "mpg", "m4v": "mp4", "aif": "aiff", "oga": "ogg",
+	"heif": "heic", "yml": "yaml",
+}
+
+// extensionMismatch compares the extension of fileName with the one
+// exiftool expects for the detected file type. It returns a message if
+// they differ, e.g. for a PNG named photo.jpg, or "" if they agree or
+// either is unknown. A mimeType that extensionMimes lists for the
+// extension is accepted either way, and for files exiftool doesn't
+// identify, such as archive entries, it's what the extension is checked
+// against.
+func extensionMismatch(fileName string, exif map[string]interface{}, mimeType string) string {
+	expected := strings.ToLower(exifString(exif, "FileTypeExtension"))
+	actual := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	if actual == "" {
+		return ""
+	}
+	if alias, ok := extensionAliases[actual]; ok {
+		actual = alias
+	}
+	if alias, ok := extensionAliases[expected]; ok {
+		expected = alias
+	}
+	if actual == expected || expectedMime(actual, mimeType) {
+		return ""
+	}
+	if expected != "" {
+		return "file extension ." + actual + " doesn't match its content, which is ." + expected
+	}
+	switch baseMime(mimeType) {
+	case "", "unknown", emptyMime, "application/octet-stream":
+		// nothing to compare against
+		return ""
+	}
+	if _, ok := extensionMimes[actual]; ok {
+		return "file extension ." + actual + " doesn't match its content, which is " + baseMime(mimeType)
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// panicError is a panic recovered while analyzing one file, so a decoder
+// choking on untrusted input fails that file instead of the whole run.
+type panicError struct {
+	value interface{}
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("internal panic: %v", e.value)
+}
+
+// recoverAnalysis turns a panic in the analysis of path into a
+// *panicError in *err. It must be deferred directly. The stack goes to
+// stderr with --verbose.
+func recoverAnalysis(path string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if opts.Verbose {
+		fmt.Fprintf(os.Stderr, "panic analyzing %s: %v\n%s", path, r, debug.Stack())
+	}
+	*err = &panicError{value: r}
+}
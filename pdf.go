@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"os"
+	"regexp"
+)
+
+// PDFInfo holds what infx finds by looking inside a PDF itself rather
+// than at exiftool's tags.
+type PDFInfo struct {
+	HasTextLayer bool `json:"has_text_layer"`
+}
+
+// maxPDFScanSize bounds the PDFs pdfHasTextLayer reads into memory, and
+// how much of each object stream it inflates.
+const maxPDFScanSize = 64 << 20
+
+// fontPattern matches a font resource or font object. Text can only be
+// drawn with a font, so a PDF without any has no text layer; an OCRed
+// scan has one, usually an invisible "GlyphLessFont".
+var fontPattern = regexp.MustCompile(`/Type\s*/Font\b|/Font\s*(<<|\d+\s+\d+\s+R)`)
+
+var objectStreamPattern = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+
+// extractPDF reports whether the PDF at filePath has a text layer, or
+// returns nil if that can't be told: the file is too large to scan or
+// --header-only is set.
+func extractPDF(filePath string) *PDFInfo {
+	if opts.HeaderOnly {
+		return nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil || info.Size() > maxPDFScanSize {
+		return nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil
+	}
+	return &PDFInfo{HasTextLayer: pdfHasTextLayer(data)}
+}
+
+// pdfHasTextLayer looks for fonts in the plain part of the file and, since
+// PDF 1.5 can compress dictionaries into object streams, in each
+// FlateDecode object stream.
+func pdfHasTextLayer(data []byte) bool {
+	if fontPattern.Match(data) {
+		return true
+	}
+	for _, loc := range objectStreamPattern.FindAllIndex(data, -1) {
+		rest := data[loc[1]:]
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			continue
+		}
+		body := bytes.TrimLeft(rest[start+len("stream"):], "\r\n")
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		r, err := zlib.NewReader(bytes.NewReader(body[:end]))
+		if err != nil {
+			continue
+		}
+		// a truncated stream still yields what inflated before the error
+		inflated, _ := io.ReadAll(io.LimitReader(r, maxPDFScanSize))
+		r.Close()
+		if fontPattern.Match(inflated) {
+			return true
+		}
+	}
+	return false
+}
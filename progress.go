@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const progressInterval = 500 * time.Millisecond
+
+// progressWriter counts the bytes written through it and periodically
+// prints how far along it is to out, which must not be stdout so the JSON
+// output stays clean.
+type progressWriter struct {
+	out     io.Writer
+	label   string
+	total   int64
+	written int64
+	last    time.Time
+}
+
+func newProgressWriter(out io.Writer, label string, total int64) *progressWriter {
+	return &progressWriter{out: out, label: label, total: total, last: time.Now()}
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.print()
+	}
+	return len(b), nil
+}
+
+func (p *progressWriter) print() {
+	if p.total > 0 {
+		fmt.Fprintf(p.out, "\r%s: %3d%%", p.label, p.written*100/p.total)
+	} else {
+		fmt.Fprintf(p.out, "\r%s: %s", p.label, humanReadableSize(p.written))
+	}
+}
+
+// finish prints the final state and ends the line.
+func (p *progressWriter) finish() {
+	p.print()
+	fmt.Fprintln(p.out)
+}
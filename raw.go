@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RawInfo separates the two resolutions a camera RAW file has: that of
+// the sensor data and that of the JPEG preview cameras embed for quick
+// display, which is often much smaller.
+type RawInfo struct {
+	SensorWidth   int `json:"sensor_width,omitempty"`
+	SensorHeight  int `json:"sensor_height,omitempty"`
+	PreviewWidth  int `json:"preview_width,omitempty"`
+	PreviewHeight int `json:"preview_height,omitempty"`
+}
+
+// rawExtensions are the file types exiftool reports for camera RAW
+// formats. Their MIME types aren't standardized, so the extension is
+// the more reliable signal.
+var rawExtensions = map[string]bool{
+	"3fr": true, "arw": true, "cr2": true, "cr3": true, "crw": true,
+	"dcr": true, "dng": true, "erf": true, "iiq": true, "kdc": true,
+	"mef": true, "mos": true, "mrw": true, "nef": true, "nrw": true,
+	"orf": true, "pef": true, "raf": true, "rw2": true, "rwl": true,
+	"sr2": true, "srf": true, "srw": true, "x3f": true,
+}
+
+var rawMimes = map[string]bool{
+	"image/x-adobe-dng":      true,
+	"image/x-canon-cr2":      true,
+	"image/x-canon-cr3":      true,
+	"image/x-canon-crw":      true,
+	"image/x-fujifilm-raf":   true,
+	"image/x-nikon-nef":      true,
+	"image/x-olympus-orf":    true,
+	"image/x-panasonic-rw2":  true,
+	"image/x-pentax-pef":     true,
+	"image/x-samsung-srw":    true,
+	"image/x-sony-arw":       true,
+	"image/x-hasselblad-3fr": true,
+}
+
+func isRaw(mimeType, fileExt string) bool {
+	return rawMimes[mimeType] || rawExtensions[fileExt]
+}
+
+// rawSensorSizeKeys are exiftool's width/height tag pairs for the RAW
+// data, most specific first. SensorWidth is a pixel count for some makers
+// and a length in mm for others; the latter doesn't parse and is skipped.
+var rawSensorSizeKeys = [][2]string{
+	{"RawImageFullWidth", "RawImageFullHeight"},
+	{"SensorWidth", "SensorHeight"},
+	{"RawImageWidth", "RawImageHeight"},
+	{"ImageWidth", "ImageHeight"},
+}
+
+// previewTags are the exiftool tags that can hold the embedded JPEG,
+// largest usually first.
+var previewTags = []string{"JpgFromRaw", "PreviewImage"}
+
+var sizePattern = regexp.MustCompile(`^(\d+)\s*[x ]\s*(\d+)$`)
+
+// extractRaw returns the sensor and preview resolutions of the RAW file
+// at filePath. The preview size comes from exiftool's tags when it has
+// them; otherwise the preview is extracted and its header decoded, which
+// --header-only skips.
+func extractRaw(filePath string, exif map[string]interface{}) *RawInfo {
+	info := &RawInfo{}
+	for _, keys := range rawSensorSizeKeys {
+		w, h := exifInt(exif, keys[0]), exifInt(exif, keys[1])
+		if w > 0 && h > 0 {
+			info.SensorWidth, info.SensorHeight = w, h
+			break
+		}
+	}
+
+	if m := sizePattern.FindStringSubmatch(strings.TrimSpace(exifString(exif, "PreviewImageSize"))); m != nil {
+		info.PreviewWidth, _ = strconv.Atoi(m[1])
+		info.PreviewHeight, _ = strconv.Atoi(m[2])
+	} else if w, h := exifInt(exif, "PreviewImageWidth"), exifInt(exif, "PreviewImageHeight"); w > 0 && h > 0 {
+		info.PreviewWidth, info.PreviewHeight = w, h
+	} else if !opts.HeaderOnly {
+		for _, tag := range previewTags {
+			if _, ok := exif[tag]; !ok {
+				continue
+			}
+			data, err := runCommand("exiftool", "-b", "-"+tag, fileArg(filePath))
+			if err != nil || len(data) == 0 {
+				continue
+			}
+			if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+				info.PreviewWidth, info.PreviewHeight = config.Width, config.Height
+				break
+			}
+		}
+	}
+
+	if *info == (RawInfo{}) {
+		return nil
+	}
+	return info
+}
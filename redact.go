@@ -0,0 +1,91 @@
+package main
+
+import (
+	"maps"
+	"path"
+	"strings"
+)
+
+// defaultRedactKeys lists EXIF keys that identify a person, a device or a
+// location. Entries are path.Match patterns, also applied to the fields of
+// the mediainfo tracks.
+var defaultRedactKeys = []string{
+	"GPS*",
+	"*SerialNumber",
+	"OwnerName",
+	"CameraOwnerName",
+	"Artist",
+}
+
+// mediaLocationKeys are the mediainfo track fields holding where a video
+// was recorded, such as the ISO 6709 string phones write. They go with
+// the GPS* EXIF keys.
+var mediaLocationKeys = []string{
+	"Recorded_Location",
+	"xyz",
+	"com_apple_quicktime_location*",
+	"com.apple.quicktime.location*",
+}
+
+// redactMap deletes every key matching one of patterns from m.
+func redactMap(m map[string]interface{}, patterns []string) {
+	for key := range m {
+		if redacts(patterns, key) {
+			delete(m, key)
+		}
+	}
+}
+
+// redactMedia returns a copy of the mediainfo output without the track
+// fields, including those under "extra", matching one of patterns. media
+// itself is left alone, as it may be shared with the cache.
+func redactMedia(media map[string]interface{}, patterns []string) map[string]interface{} {
+	mediaRoot, ok := media["media"].(map[string]interface{})
+	if !ok {
+		return media
+	}
+	rawTracks, ok := mediaRoot["track"].([]interface{})
+	if !ok {
+		return media
+	}
+	tracks := make([]interface{}, len(rawTracks))
+	for i, t := range rawTracks {
+		if track, ok := t.(map[string]interface{}); ok {
+			track = maps.Clone(track)
+			redactMap(track, patterns)
+			if extra, ok := track["extra"].(map[string]interface{}); ok {
+				extra = maps.Clone(extra)
+				redactMap(extra, patterns)
+				track["extra"] = extra
+			}
+			t = track
+		}
+		tracks[i] = t
+	}
+	mediaRoot = maps.Clone(mediaRoot)
+	mediaRoot["track"] = tracks
+	media = maps.Clone(media)
+	media["media"] = mediaRoot
+	return media
+}
+
+// redacts reports whether key matches one of patterns.
+func redacts(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
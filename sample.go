@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// sampleFingerprint hashes the file size and three samples of n bytes,
+// from the start, middle and end of the file, into one sha256. Files of
+// at most 3n bytes are hashed whole. It only reads 3n bytes however
+// large the file is, at the price of being a heuristic: files differing
+// only outside the samples get the same fingerprint, so it is for
+// finding likely duplicates, not for integrity or security.
+func sampleFingerprint(filePath string, n int64) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, uint64(size))
+	offsets := []int64{0}
+	length := size
+	if size > 3*n {
+		offsets = []int64{0, (size - n) / 2, size - n}
+		length = n
+	}
+	for _, offset := range offsets {
+		if _, err := io.Copy(h, io.NewSectionReader(file, offset, length)); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// outputSchema builds a JSON Schema for the output document from its
+// struct definition, so the schema can't drift from what we actually
+// marshal. With --summary that's Summary instead of MediaMetadata, and
+// --grouped and --json-key-style reshape it the same way as the output.
+func outputSchema() map[string]interface{} {
+	t := reflect.TypeOf(MediaMetadata{})
+	if opts.Summary {
+		t = reflect.TypeOf(Summary{})
+	}
+	schema := schemaForType(t)
+	if opts.Grouped {
+		schema = groupSchema(schema)
+	}
+	if opts.KeyStyle == "camel" {
+		schema = camelSchema(schema)
+	}
+	schema["$schema"] = schemaDraft
+	schema["title"] = t.Name()
+	return schema
+}
+
+// schemaVisiting holds the structs schemaForType is inside of.
+var schemaVisiting = make(map[reflect.Type]bool)
+
+func schemaForType(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaForType(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaForType(t.Elem()),
+		}
+	case reflect.Map:
+		schema := map[string]interface{}{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema["additionalProperties"] = schemaForType(t.Elem())
+		}
+		return schema
+	case reflect.Struct:
+		// a struct nested in itself, like the results under embedded,
+		// is described as any object rather than recursing forever
+		if schemaVisiting[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		schemaVisiting[t] = true
+		defer delete(schemaVisiting, t)
+		return schemaForStruct(t)
+	}
+	// interface{} and anything else we can't describe accepts any value
+	return map[string]interface{}{}
+}
+
+func schemaForStruct(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, optional := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+		property := schemaForType(field.Type)
+		if !optional {
+			required = append(required, name)
+			// a nil map, slice or pointer is still written out, as null
+			switch field.Type.Kind() {
+			case reflect.Map, reflect.Slice, reflect.Ptr:
+				if typ, ok := property["type"].(string); ok {
+					property["type"] = []string{typ, "null"}
+				}
+			}
+		}
+		properties[name] = property
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// jsonFieldName returns the name encoding/json uses for the field and
+// whether it is omitted when empty.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			return name, true
+		}
+	}
+	return name, false
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shortLine renders result as the one line --short prints, e.g.
+// "photo.jpg  image/jpeg  4000x3000  3.2 MB  sha256:ab12cd34ef56…". Which
+// fields appear depends on the kind of media: dimensions for images,
+// duration for audio, both for video. Fields that are unknown are left
+// out.
+func shortLine(result *MediaMetadata) string {
+	fields := []string{result.FileName, result.MimeType}
+	dimensions := ""
+	if result.DisplayWidth > 0 && result.DisplayHeight > 0 {
+		dimensions = fmt.Sprintf("%dx%d", result.DisplayWidth, result.DisplayHeight)
+	} else if result.Width > 0 && result.Height > 0 {
+		dimensions = fmt.Sprintf("%dx%d", result.Width, result.Height)
+	}
+	duration := result.Duration
+	if duration == "Unknown" {
+		duration = ""
+	}
+	switch {
+	case strings.HasPrefix(result.MimeType, "video/"):
+		fields = append(fields, dimensions, duration)
+	case strings.HasPrefix(result.MimeType, "audio/"):
+		fields = append(fields, duration)
+	case strings.HasPrefix(result.MimeType, "image/"):
+		fields = append(fields, dimensions)
+	}
+	fields = append(fields, result.FileSizeHuman)
+	if sum := result.Hashes["sha256"]; sum != "" {
+		fields = append(fields, "sha256:"+sum[:min(len(sum), 12)]+"…")
+	}
+
+	var line []string
+	for _, field := range fields {
+		if field != "" {
+			line = append(line, field)
+		}
+	}
+	return strings.Join(line, "  ")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findXMPSidecar returns the XMP sidecar belonging to filePath, checking
+// both the "photo.jpg.xmp" and the "photo.xmp" naming conventions.
+func findXMPSidecar(filePath string) string {
+	base := strings.TrimSuffix(filePath, filepath.Ext(filePath))
+	candidates := []string{
+		filePath + ".xmp", filePath + ".XMP",
+		base + ".xmp", base + ".XMP",
+	}
+	for _, candidate := range candidates {
+		if candidate == filePath {
+			continue
+		}
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// mergeXMPSidecar reads the XMP tags of sidecarPath into exif. Sidecar
+// values win, since that's where editors store changes they didn't write
+// back into the original.
+func mergeXMPSidecar(exif map[string]interface{}, sidecarPath string) error {
+	out, err := runCommand("exiftool", "-j", "-XMP:All", fileArg(sidecarPath))
+	if err != nil {
+		return err
+	}
+	var data []map[string]interface{}
+	if err := json.Unmarshal(out, &data); err != nil {
+		return fmt.Errorf("failed to parse exiftool output: %w", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	for key, value := range data[0] {
+		if key == "SourceFile" {
+			continue
+		}
+		exif[key] = value
+	}
+	return nil
+}
+
+// outputSidecarSuffix names the files --sidecar-out writes next to each
+// analyzed file.
+const outputSidecarSuffix = ".infx.json"
+
+// writeOutputSidecar writes data to filePath's output sidecar. An
+// existing sidecar is only replaced when force is set.
+func writeOutputSidecar(filePath string, data []byte, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(filePath+outputSidecarSuffix, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// bytesHashed counts every byte computeHashes has digested, for --stats.
+var bytesHashed atomic.Int64
+
+// batchStats tallies a batch run for --stats.
+type batchStats struct {
+	start  time.Time
+	files  int
+	errors int
+	mimes  map[string]int
+}
+
+func newBatchStats() *batchStats {
+	return &batchStats{start: time.Now(), mimes: make(map[string]int)}
+}
+
+// add counts the file behind out. Files skipped before being analyzed
+// aren't counted.
+func (s *batchStats) add(out batchOutput) {
+	switch {
+	case out.errored:
+		s.files++
+		s.errors++
+	case out.analyzed:
+		s.files++
+		s.mimes[out.mime]++
+	}
+}
+
+// mimeCounts returns the MIME types seen, most frequent first.
+func (s *batchStats) mimeCounts() []string {
+	mimes := make([]string, 0, len(s.mimes))
+	for mime := range s.mimes {
+		mimes = append(mimes, mime)
+	}
+	slices.SortFunc(mimes, func(a, b string) int {
+		if c := cmp.Compare(s.mimes[b], s.mimes[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return mimes
+}
+
+// write prints the stats to w in opts.StatsFormat.
+func (s *batchStats) write(w io.Writer) error {
+	elapsed := time.Since(s.start).Seconds()
+	hashed := bytesHashed.Load()
+	var b strings.Builder
+	if opts.StatsFormat == "prometheus" {
+		metric := func(name, kind, help string) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		}
+		metric("infx_files_processed_total", "counter", "Files analyzed, including those that failed.")
+		fmt.Fprintf(&b, "infx_files_processed_total %d\n", s.files)
+		metric("infx_files_failed_total", "counter", "Files that couldn't be analyzed.")
+		fmt.Fprintf(&b, "infx_files_failed_total %d\n", s.errors)
+		metric("infx_bytes_hashed_total", "counter", "Bytes read to compute file hashes.")
+		fmt.Fprintf(&b, "infx_bytes_hashed_total %d\n", hashed)
+		metric("infx_files_by_mime_type_total", "counter", "Files analyzed per detected MIME type.")
+		for _, mime := range s.mimeCounts() {
+			fmt.Fprintf(&b, "infx_files_by_mime_type_total{mime_type=\"%s\"} %d\n", prometheusLabel.Replace(mime), s.mimes[mime])
+		}
+		metric("infx_run_duration_seconds", "gauge", "Wall time of the run.")
+		fmt.Fprintf(&b, "infx_run_duration_seconds %.3f\n", elapsed)
+	} else {
+		fmt.Fprintf(&b, "Files processed: %d\n", s.files)
+		fmt.Fprintf(&b, "Errors: %d\n", s.errors)
+		fmt.Fprintf(&b, "Bytes hashed: %d (%s)\n", hashed, humanReadableSize(hashed))
+		fmt.Fprintf(&b, "Wall time: %.3fs\n", elapsed)
+		for _, mime := range s.mimeCounts() {
+			fmt.Fprintf(&b, "  %-30s %d\n", cmp.Or(mime, "unknown"), s.mimes[mime])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// prometheusLabel escapes a label value for the text exposition format.
+var prometheusLabel = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// report writes the stats to --stats-file, or stderr without one.
+func (s *batchStats) report() error {
+	if opts.StatsFile == "" {
+		return s.write(os.Stderr)
+	}
+	f, err := os.Create(opts.StatsFile)
+	if err != nil {
+		return err
+	}
+	if err := s.write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// tgsMime is the MIME type of Telegram's animated stickers: Lottie JSON
+// compressed with gzip.
+const tgsMime = "application/x-tgsticker"
+
+// maxTGSSize bounds how much decompressed data isTGS reads. Telegram
+// caps stickers at 64 KiB compressed, so anything near this isn't one.
+const maxTGSSize = 8 << 20
+
+// isTGS reports whether the gzip file at filePath holds a Lottie
+// animation, recognized by its frame rate and layers keys.
+func isTGS(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return false
+	}
+	var lottie map[string]json.RawMessage
+	if err := json.NewDecoder(io.LimitReader(gz, maxTGSSize)).Decode(&lottie); err != nil {
+		return false
+	}
+	_, hasFrameRate := lottie["fr"]
+	_, hasLayers := lottie["layers"]
+	return hasFrameRate && hasLayers
+}
+
+// stickerKind classifies the formats messaging platforms use for
+// stickers. Other files, including still PNGs, get "".
+func stickerKind(mimeType string, animated bool) string {
+	switch {
+	case mimeType == tgsMime:
+		return "tgs"
+	case mimeType == "image/webp" && animated:
+		return "animated_webp"
+	case mimeType == "image/webp":
+		return "static_webp"
+	case (mimeType == "image/png" || mimeType == "image/apng") && animated:
+		return "apng"
+	}
+	return ""
+}
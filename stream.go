@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// stdinPath is the file argument that reads the input from stdin.
+const stdinPath = "-"
+
+// byteCounter counts the bytes written to it.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// analyzeStream describes what is read from r with --hash-from-stream:
+// size, MIME type from the first bytes, and hashes, all computed while
+// reading so the input is never stored. exiftool and mediainfo need a
+// file, so they aren't run.
+func analyzeStream(r io.Reader) (*MediaMetadata, error) {
+	result, err := analyzeReader(r, "stdin", 0, "read from a stream: exiftool and mediainfo were not run")
+	if err != nil {
+		return nil, err
+	}
+	result.FileName = stdinPath
+	return result, nil
+}
+
+// analyzeReader does the work of analyzeStream for any reader, labelled
+// label in progress output and errors. size is the expected length, or 0
+// if unknown. note explains the STREAM_ONLY note. The result has no
+// FileName while the extractors run, so none of them opens a file that
+// isn't what was read; the caller sets it.
+func analyzeReader(r io.Reader, label string, size int64, note string) (*MediaMetadata, error) {
+	var n byteCounter
+	var digests fileDigests
+	if hashingEnabled(size) {
+		var err error
+		if digests, err = hashStream(r, label, size, &n); err != nil {
+			return nil, fmt.Errorf("error computing hashes: %w", err)
+		}
+	} else {
+		head := &headWriter{buf: make([]byte, 0, sniffLen)}
+		if _, err := io.Copy(io.MultiWriter(head, &n), contextReader{runCtx, r}); err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", label, err)
+		}
+		digests.head = head.buf
+	}
+
+	result := &MediaMetadata{
+		FileSize:      int64(n),
+		FileSizeHuman: humanReadableSize(int64(n)),
+		Notes:         []Note{newNote(noteStreamOnly, note)},
+		EXIF:          map[string]interface{}{},
+		Media:         map[string]interface{}{},
+	}
+	if n == 0 {
+		result.MimeType = emptyMime
+	} else {
+		var mimeWarning string
+		result.MimeType, result.MimeSource, mimeWarning = getMimeType("", nil, digests.head)
+		if mimeWarning != "" {
+			result.Notes = append(result.Notes, newNote(noteMimeMismatch, mimeWarning))
+		}
+	}
+	digests.apply(result)
+	runExtractors(result.EXIF, result.Media, result)
+	return result, nil
+}
+
+// bufferStdin copies stdin to a temporary file, which the tools need to
+// analyze it. The caller removes the file.
+func bufferStdin() (string, error) {
+	f, err := os.CreateTemp("", "infx-stdin-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, contextReader{runCtx, os.Stdin}); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// analyzeStdin buffers stdin to a temporary file and analyzes that like
+// any other file. The cache isn't used, as the path means nothing.
+func analyzeStdin() (*MediaMetadata, error) {
+	path, err := bufferStdin()
+	if err != nil {
+		return nil, fmt.Errorf("error buffering stdin: %w", err)
+	}
+	defer os.Remove(path)
+	result, err := analyzePath(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.FileName = stdinPath
+	result.FS = nil
+	return result, nil
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type VideoStream struct {
+	Codec              string `json:"codec"`
+	BitRate            int64  `json:"bit_rate,omitempty"`
+	BitRateHuman       string `json:"bit_rate_human,omitempty"`
+	FrameRateMode      string `json:"frame_rate_mode"`
+	FieldOrder         string `json:"field_order"`
+	Profile            string `json:"profile,omitempty"`
+	Level              string `json:"level,omitempty"`
+	Tier               string `json:"tier,omitempty"`
+	Width              int    `json:"width,omitempty"`
+	Height             int    `json:"height,omitempty"`
+	RotationDegrees    int    `json:"rotation_degrees,omitempty"`
+	DisplayWidth       int    `json:"display_width,omitempty"`
+	DisplayHeight      int    `json:"display_height,omitempty"`
+	HasDolbyVision     bool   `json:"has_dolby_vision,omitempty"`
+	DolbyVisionProfile string `json:"dolby_vision_profile,omitempty"`
+}
+
+type AudioStream struct {
+	Codec              string `json:"codec"`
+	BitRate            int64  `json:"bit_rate,omitempty"`
+	BitRateHuman       string `json:"bit_rate_human,omitempty"`
+	IsObjectAudio      bool   `json:"is_object_audio,omitempty"`
+	SpatialAudioFormat string `json:"spatial_audio_format,omitempty"`
+	Language           string `json:"language,omitempty"`
+	LanguageName       string `json:"language_name,omitempty"`
+	IsDefault          *bool  `json:"is_default,omitempty"`
+	IsForced           *bool  `json:"is_forced,omitempty"`
+	IsLossless         *bool  `json:"is_lossless,omitempty"`
+}
+
+type SubtitleStream struct {
+	Format       string `json:"format"`
+	Language     string `json:"language,omitempty"`
+	LanguageName string `json:"language_name,omitempty"`
+	IsDefault    *bool  `json:"is_default,omitempty"`
+	IsForced     *bool  `json:"is_forced,omitempty"`
+}
+
+type TrackCounts struct {
+	Video int `json:"video"`
+	Audio int `json:"audio"`
+	Text  int `json:"text"`
+	Image int `json:"image"`
+	Other int `json:"other"`
+}
+
+// countTracks tallies mediainfo tracks by type. The General track
+// describes the container rather than a stream and isn't counted.
+func countTracks(media map[string]interface{}) TrackCounts {
+	var counts TrackCounts
+	for _, track := range mediaTracks(media) {
+		switch track["@type"] {
+		case "General":
+		case "Video":
+			counts.Video++
+		case "Audio":
+			counts.Audio++
+		case "Text":
+			counts.Text++
+		case "Image":
+			counts.Image++
+		default:
+			counts.Other++
+		}
+	}
+	return counts
+}
+
+// containerFormat returns the container format mediainfo reports in the
+// General track, e.g. "MPEG-4" or "Matroska".
+func containerFormat(media map[string]interface{}) string {
+	for _, track := range mediaTracks(media) {
+		if track["@type"] == "General" {
+			format, _ := track["Format"].(string)
+			return format
+		}
+	}
+	return ""
+}
+
+// extractStreams describes each video, audio and subtitle stream
+// separately from the container holding them.
+func extractStreams(media map[string]interface{}) ([]VideoStream, []AudioStream, []SubtitleStream) {
+	var video []VideoStream
+	var audio []AudioStream
+	var subtitles []SubtitleStream
+	for _, track := range mediaTracks(media) {
+		format, _ := track["Format"].(string)
+		rawLanguage, _ := track["Language"].(string)
+		language, languageName := normalizeLanguage(rawLanguage)
+		bitRate := trackBitRate(track, "BitRate", "BitRate_Nominal")
+		switch track["@type"] {
+		case "Video":
+			stream := VideoStream{
+				Codec:           format,
+				BitRate:         bitRate,
+				BitRateHuman:    humanBitRate(bitRate),
+				FrameRateMode:   frameRateMode(track),
+				FieldOrder:      fieldOrder(track),
+				RotationDegrees: trackRotation(track),
+			}
+			stream.Profile, stream.Level, stream.Tier = codecProfile(track)
+			stream.HasDolbyVision, stream.DolbyVisionProfile = dolbyVision(track)
+			if width, ok := trackFloat(track, "Width"); ok {
+				stream.Width = int(width)
+			}
+			if height, ok := trackFloat(track, "Height"); ok {
+				stream.Height = int(height)
+			}
+			stream.DisplayWidth, stream.DisplayHeight = rotatedDimensions(stream.Width, stream.Height, stream.RotationDegrees)
+			video = append(video, stream)
+		case "Audio":
+			spatial := spatialAudioFormat(track)
+			audio = append(audio, AudioStream{
+				Codec:              format,
+				BitRate:            bitRate,
+				BitRateHuman:       humanBitRate(bitRate),
+				IsObjectAudio:      spatial != "",
+				SpatialAudioFormat: spatial,
+				Language:           language,
+				LanguageName:       languageName,
+				IsDefault:          trackFlag(track, "Default"),
+				IsForced:           trackFlag(track, "Forced"),
+				IsLossless:         audioLossless(track, format),
+			})
+		case "Text":
+			subtitles = append(subtitles, SubtitleStream{
+				Format:       format,
+				Language:     language,
+				LanguageName: languageName,
+				IsDefault:    trackFlag(track, "Default"),
+				IsForced:     trackFlag(track, "Forced"),
+			})
+		}
+	}
+	return video, audio, subtitles
+}
+
+// codecProfile returns the profile, level and (for HEVC) tier of a video
+// track. mediainfo's JSON output has them in separate fields, while other
+// outputs combine them as "High@L4.1" or "Main 10@L5.1@High".
+func codecProfile(track map[string]interface{}) (profile, level, tier string) {
+	combined, _ := track["Format_Profile"].(string)
+	parts := strings.Split(combined, "@")
+	profile = strings.TrimSpace(parts[0])
+	if len(parts) > 1 {
+		level = strings.TrimPrefix(strings.TrimSpace(parts[1]), "L")
+	}
+	if len(parts) > 2 {
+		tier = strings.TrimSpace(parts[2])
+	}
+	if s, ok := track["Format_Level"].(string); ok && s != "" {
+		level = s
+	}
+	if s, ok := track["Format_Tier"].(string); ok && s != "" {
+		tier = s
+	}
+	return profile, level, tier
+}
+
+// fieldOrder tells how a video track is scanned: "progressive", or for
+// interlaced video which field comes first, "TFF" (top) or "BFF"
+// (bottom). It is "unknown" when mediainfo doesn't say, including for
+// interlaced video without a ScanOrder.
+func fieldOrder(track map[string]interface{}) string {
+	scanType, _ := track["ScanType"].(string)
+	scanOrder, _ := track["ScanOrder"].(string)
+	if strings.EqualFold(scanType, "Progressive") {
+		return "progressive"
+	}
+	// e.g. "TFF", or "TFF/BFF" for a stream that changes order midway
+	switch order := strings.ToUpper(strings.TrimSpace(scanOrder)); {
+	case strings.HasPrefix(order, "TFF"):
+		return "TFF"
+	case strings.HasPrefix(order, "BFF"):
+		return "BFF"
+	}
+	return "unknown"
+}
+
+// dolbyVisionProfilePattern matches a Dolby Vision codec string such as
+// "dvhe.05" or "dav1.10".
+var dolbyVisionProfilePattern = regexp.MustCompile(`\bd[a-z0-9]{3}\.\d{2}\b`)
+
+// dolbyVision reports whether a video track carries Dolby Vision (an RPU
+// mediainfo found) and its profile. mediainfo lists every HDR format of a
+// track in HDR_Format, e.g. "Dolby Vision / SMPTE ST 2086", with the
+// matching profiles in the same positions of HDR_Format_Profile.
+func dolbyVision(track map[string]interface{}) (bool, string) {
+	formats, _ := track["HDR_Format"].(string)
+	profiles, _ := track["HDR_Format_Profile"].(string)
+	formatList := strings.Split(formats, " / ")
+	profileList := strings.Split(profiles, " / ")
+	for i, format := range formatList {
+		if !strings.Contains(format, "Dolby Vision") {
+			continue
+		}
+		if i < len(profileList) {
+			return true, dolbyVisionProfilePattern.FindString(profileList[i])
+		}
+		return true, dolbyVisionProfilePattern.FindString(profiles)
+	}
+	return false, ""
+}
+
+// trackFlag parses a mediainfo Yes/No field, returning nil if the track
+// doesn't have it.
+func trackFlag(track map[string]interface{}, key string) *bool {
+	value, _ := track[key].(string)
+	switch strings.ToLower(value) {
+	case "yes":
+		flag := true
+		return &flag
+	case "no":
+		flag := false
+		return &flag
+	}
+	return nil
+}
+
+// audioCodecLossless says whether audio in a mediainfo format is lossless,
+// for codecs that are only ever one or the other.
+var audioCodecLossless = map[string]bool{
+	"flac":           true,
+	"alac":           true,
+	"pcm":            true,
+	"wavpack":        true,
+	"monkey's audio": true,
+	"tta":            true,
+	"mlp fba":        true,
+	"mpeg audio":     false,
+	"aac":            false,
+	"opus":           false,
+	"vorbis":         false,
+	"ac-3":           false,
+	"e-ac-3":         false,
+	"wma":            false,
+}
+
+// audioLossless reports whether an audio track is lossless, returning nil
+// for codecs we don't know. mediainfo's Compression_Mode takes precedence
+// when present, since it covers codecs with both modes such as DTS-HD and
+// hybrid WavPack.
+func audioLossless(track map[string]interface{}, format string) *bool {
+	mode, _ := track["Compression_Mode"].(string)
+	switch strings.ToLower(mode) {
+	case "lossless":
+		lossless := true
+		return &lossless
+	case "lossy":
+		lossless := false
+		return &lossless
+	}
+	if lossless, ok := audioCodecLossless[strings.ToLower(format)]; ok {
+		return &lossless
+	}
+	return nil
+}
+
+// spatialAudioFormats maps markers of object-based audio in mediainfo's
+// format fields to the name reported for them.
+var spatialAudioFormats = []struct {
+	marker string
+	name   string
+}{
+	{"atmos", "Dolby Atmos"},
+	{"joc", "Dolby Atmos"},
+	{"dts:x", "DTS:X"},
+	{"dts-x", "DTS:X"},
+	{"xll x", "DTS:X"},
+	{"auro-3d", "Auro-3D"},
+	{"mpeg-h", "MPEG-H 3D Audio"},
+}
+
+// spatialAudioFormat names the object-based audio format of an audio
+// track, or "" for channel-based audio. mediainfo spells it out in the
+// commercial name ("Dolby Digital Plus with Dolby Atmos") or flags it in
+// the format additional features ("JOC" for E-AC-3 Atmos, "XLL X" for
+// DTS:X).
+func spatialAudioFormat(track map[string]interface{}) string {
+	var fields []string
+	for _, key := range []string{"Format_Commercial_IfAny", "Format_Commercial", "Format", "Format_AdditionalFeatures", "Format_Profile"} {
+		if s, ok := track[key].(string); ok {
+			fields = append(fields, strings.ToLower(s))
+		}
+	}
+	text := strings.Join(fields, " | ")
+	for _, format := range spatialAudioFormats {
+		if strings.Contains(text, format.marker) {
+			return format.name
+		}
+	}
+	return ""
+}
+
+// trackRotation returns the clockwise rotation mediainfo reports for a
+// video track, normalized to 0, 90, 180 or 270. The value comes as a
+// float string such as "90.000", and some muxers write -90 for 270.
+func trackRotation(track map[string]interface{}) int {
+	rotation, ok := trackFloat(track, "Rotation")
+	if !ok {
+		return 0
+	}
+	degrees := int(math.Round(rotation/90)) * 90 % 360
+	if degrees < 0 {
+		degrees += 360
+	}
+	return degrees
+}
+
+// rotatedDimensions returns the size a video is shown at once its
+// rotation is applied, swapping width and height for 90 and 270.
+func rotatedDimensions(width, height, rotation int) (int, int) {
+	if rotation == 90 || rotation == 270 {
+		return height, width
+	}
+	return width, height
+}
+
+// overallBitRate returns the General track's overall bit rate in bits per
+// second, or 0 when mediainfo didn't report one.
+func overallBitRate(media map[string]interface{}) int64 {
+	for _, track := range mediaTracks(media) {
+		if track["@type"] == "General" {
+			return trackBitRate(track, "OverallBitRate", "OverallBitRate_Nominal")
+		}
+	}
+	return 0
+}
+
+func trackBitRate(track map[string]interface{}, keys ...string) int64 {
+	for _, key := range keys {
+		if s, ok := track[key].(string); ok {
+			if bps, ok := parseBitRate(s); ok {
+				return bps
+			}
+		}
+	}
+	return 0
+}
+
+// parseBitRate parses a bit rate in bits per second. Besides the plain
+// numbers of mediainfo's JSON output it accepts the human form used by its
+// text output, where spaces group thousands: "1 234 kb/s".
+func parseBitRate(s string) (int64, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	multiplier := 1.0
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{
+		{"gb/s", 1e9}, {"mb/s", 1e6}, {"kb/s", 1e3}, {"b/s", 1},
+		{"gbps", 1e9}, {"mbps", 1e6}, {"kbps", 1e3}, {"bps", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSuffix(s, unit.suffix)
+			multiplier = unit.factor
+			break
+		}
+	}
+	s = strings.ReplaceAll(s, " ", "")
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return int64(value*multiplier + 0.5), true
+}
+
+// humanBitRate formats bits per second like "1.2 Mb/s".
+func humanBitRate(bps int64) string {
+	if bps <= 0 {
+		return ""
+	}
+	const unit = 1000
+	if bps < unit {
+		return fmt.Sprintf("%d b/s", bps)
+	}
+	div, exp := int64(unit), 0
+	for n := bps / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	units := []string{"kb/s", "Mb/s", "Gb/s", "Tb/s"}
+	return fmt.Sprintf("%.1f %s", float64(bps)/float64(div), units[exp])
+}
+
+// frameRateMode reports "CFR" or "VFR" from mediainfo's FrameRate_Mode,
+// and "unknown" when mediainfo didn't determine it.
+func frameRateMode(track map[string]interface{}) string {
+	mode, _ := track["FrameRate_Mode"].(string)
+	switch strings.ToUpper(strings.TrimSpace(mode)) {
+	case "CFR", "CONSTANT":
+		return "CFR"
+	case "VFR", "VARIABLE":
+		return "VFR"
+	}
+	return "unknown"
+}
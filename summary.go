@@ -0,0 +1,68 @@
+package main
+
+// summarySchemaVersion is bumped whenever a field of Summary changes
+// meaning or is removed. Adding fields doesn't bump it.
+const summarySchemaVersion = 1
+
+// Summary is the stable subset of MediaMetadata emitted by --summary. It
+// only holds fields infx derives itself, so it doesn't change shape when
+// exiftool or mediainfo start reporting new tags.
+type Summary struct {
+	SchemaVersion           int               `json:"schema_version"`
+	FileName                string            `json:"file_name"`
+	MimeType                string            `json:"mime_type"`
+	FileExt                 string            `json:"file_extension"`
+	FileSize                int64             `json:"file_size"`
+	ContainerFormat         string            `json:"container_format,omitempty"`
+	Duration                string            `json:"duration"`
+	OverallBitRate          int64             `json:"overall_bit_rate,omitempty"`
+	CreatedAt               string            `json:"created_at,omitempty"`
+	CreatedAtUTC            string            `json:"created_at_utc,omitempty"`
+	ModifiedAt              string            `json:"modified_at,omitempty"`
+	ModifiedAtUTC           string            `json:"modified_at_utc,omitempty"`
+	Width                   int               `json:"width,omitempty"`
+	Height                  int               `json:"height,omitempty"`
+	DisplayWidth            int               `json:"display_width,omitempty"`
+	DisplayHeight           int               `json:"display_height,omitempty"`
+	PageCount               int               `json:"page_count,omitempty"`
+	FrameCount              int               `json:"frame_count,omitempty"`
+	TrackCounts             TrackCounts       `json:"track_counts"`
+	MediaIsAnimation        bool              `json:"media_is_animation"`
+	MediaIsEncrypted        bool              `json:"media_is_encrypted"`
+	MediaVideoWithAudioOnly bool              `json:"media_video_with_audio_only"`
+	IsTruncated             bool              `json:"is_truncated"`
+	IsMotionPhoto           bool              `json:"is_motion_photo"`
+	Hashes                  map[string]string `json:"hashes,omitempty"`
+	Environment             *Environment      `json:"environment,omitempty"`
+}
+
+func newSummary(m *MediaMetadata) *Summary {
+	return &Summary{
+		SchemaVersion:           summarySchemaVersion,
+		FileName:                m.FileName,
+		MimeType:                m.MimeType,
+		FileExt:                 m.FileExt,
+		FileSize:                m.FileSize,
+		ContainerFormat:         m.ContainerFormat,
+		Duration:                m.Duration,
+		OverallBitRate:          m.OverallBitRate,
+		CreatedAt:               m.CreatedAt,
+		CreatedAtUTC:            m.CreatedAtUTC,
+		ModifiedAt:              m.ModifiedAt,
+		ModifiedAtUTC:           m.ModifiedAtUTC,
+		Width:                   m.Width,
+		Height:                  m.Height,
+		DisplayWidth:            m.DisplayWidth,
+		DisplayHeight:           m.DisplayHeight,
+		PageCount:               m.PageCount,
+		FrameCount:              m.FrameCount,
+		TrackCounts:             m.TrackCounts,
+		MediaIsAnimation:        m.MediaIsAnimation,
+		MediaIsEncrypted:        m.MediaIsEncrypted,
+		MediaVideoWithAudioOnly: m.MediaVideoWithAudioOnly,
+		IsTruncated:             m.IsTruncated,
+		IsMotionPhoto:           m.IsMotionPhoto,
+		Hashes:                  m.Hashes,
+		Environment:             m.Environment,
+	}
+}
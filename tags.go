@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AudioTags are the ID3/Vorbis/iTunes tags of a music file, as exiftool
+// reads them. Missing tags are omitted.
+type AudioTags struct {
+	Title       string `json:"title,omitempty"`
+	Artist      string `json:"artist,omitempty"`
+	Album       string `json:"album,omitempty"`
+	TrackNumber int    `json:"track_number,omitempty"`
+	TrackTotal  int    `json:"track_total,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Year        int    `json:"year,omitempty"`
+}
+
+// trackPattern matches "3", "3/12" and "3 of 12".
+var trackPattern = regexp.MustCompile(`^(\d+)\s*(?:(?:/|of)\s*(\d+))?$`)
+
+// genreCodePattern matches the ID3v1 numeric genre references some
+// writers leave around the name, e.g. "(17)Rock".
+var genreCodePattern = regexp.MustCompile(`\(\d+\)`)
+
+var yearPattern = regexp.MustCompile(`^\d{4}`)
+
+// extractAudioTags returns the tags object, or nil if the file has none.
+func extractAudioTags(exif map[string]interface{}) *AudioTags {
+	tags := &AudioTags{
+		Title:  strings.TrimSpace(exifString(exif, "Title")),
+		Artist: strings.TrimSpace(exifString(exif, "Artist", "Band", "AlbumArtist")),
+		Album:  strings.TrimSpace(exifString(exif, "Album")),
+		Genre:  strings.TrimSpace(genreCodePattern.ReplaceAllString(exifString(exif, "Genre"), "")),
+	}
+	tags.TrackNumber, tags.TrackTotal = parseTrack(exifString(exif, "Track", "TrackNumber"))
+	if tags.TrackTotal == 0 {
+		tags.TrackTotal = exifInt(exif, "TrackTotal", "TotalTracks")
+	}
+	if year := yearPattern.FindString(exifString(exif, "Year", "Date", "RecordingTime", "ContentCreateDate")); year != "" {
+		tags.Year, _ = strconv.Atoi(year)
+	}
+
+	if *tags == (AudioTags{}) {
+		return nil
+	}
+	return tags
+}
+
+// parseTrack splits a track tag into its number and, if given, the total
+// number of tracks.
+func parseTrack(value string) (int, int) {
+	m := trackPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, 0
+	}
+	number, _ := strconv.Atoi(m[1])
+	total, _ := strconv.Atoi(m[2])
+	return number, total
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+type ThumbnailInfo struct {
+	Found  bool   `json:"found"`
+	Source string `json:"source,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// thumbnailTags are the exiftool tags holding an embedded preview, most
+// preferred first. The last two cover album art in audio files.
+var thumbnailTags = []string{"ThumbnailImage", "PreviewImage", "CoverArt", "Picture"}
+
+// extractThumbnail writes the first embedded preview image of filePath to
+// dir. Files are named after their SHA-256 so identical previews from
+// different sources share one file.
+func extractThumbnail(filePath, dir string, exif map[string]interface{}) (*ThumbnailInfo, error) {
+	for _, tag := range thumbnailTags {
+		if _, ok := exif[tag]; !ok {
+			continue
+		}
+		data, err := runCommand("exiftool", "-b", "-"+tag, fileArg(filePath))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		sum := sha256.Sum256(data)
+		digest := hex.EncodeToString(sum[:])
+		ext := ".bin"
+		switch http.DetectContentType(data) {
+		case "image/jpeg":
+			ext = ".jpg"
+		case "image/png":
+			ext = ".png"
+		case "image/gif":
+			ext = ".gif"
+		case "image/webp":
+			ext = ".webp"
+		}
+
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, digest+ext)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write thumbnail: %w", err)
+		}
+		return &ThumbnailInfo{
+			Found:  true,
+			Source: tag,
+			Path:   path,
+			Size:   int64(len(data)),
+			SHA256: digest,
+		}, nil
+	}
+	return &ThumbnailInfo{Found: false}, nil
+}
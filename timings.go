@@ -0,0 +1,20 @@
+package main
+
+import "time"
+
+// Timings records where the analysis of one file spent its time, in
+// milliseconds. Stages that didn't run are zero. A result served from the
+// cache only has a total.
+type Timings struct {
+	ExifMs      float64 `json:"exif_ms"`
+	MediaInfoMs float64 `json:"mediainfo_ms"`
+	HashingMs   float64 `json:"hashing_ms"`
+	TotalMs     float64 `json:"total_ms"`
+	Cached      bool    `json:"cached,omitempty"`
+}
+
+// millisSince returns the time elapsed since start in milliseconds, to
+// the microsecond.
+func millisSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reservedToolArgs lists, per tool, the options that would change the
+// output format infx parses or make the tool do something other than
+// print metadata. Options are matched case-insensitively, and a reserved
+// prefix ending in "=" also covers its values.
+var reservedToolArgs = map[string][]string{
+	"exiftool": {
+		"-j", "-json", "-x", "-xmlformat", "-csv", "-h", "-htmlformat", "-php",
+		"-t", "-tab", "-table", "-s3", "-b", "-binary", "-p", "-printformat",
+		"-w", "-textout", "-o", "-out", "-g", "-groupheadings", "-g0", "-g1",
+		"-g2", "-g3", "-g4", "-g5", "-g6", "-g7", "-stay_open", "-@",
+		"-ver", "-listx", "-l", "-long", "-n", "--printconv",
+	},
+	"mediainfo": {
+		"--output", "--output=", "--inform", "--inform=", "--language=",
+		"--logfile=", "--help", "--version", "--info-parameters",
+	},
+}
+
+// exiftoolWriteArgs are the exiftool options that write, rename, copy or
+// delete files, or run further commands, rather than print metadata.
+// "-w" covers -w+, -w! and -wext and "-execute" its numbered forms.
+var exiftoolWriteArgs = []string{
+	"-w", "-tagsfromfile", "-addtagsfromfile", "-overwrite_original",
+	"-delete_original", "-restore_original", "-geotag", "-geosync",
+	"-geotime", "-execute", "-config", "-common_args", "-srcfile",
+}
+
+// splitToolArgs splits a --exiftool-args or --mediainfo-args value into
+// arguments at whitespace. Single or double quotes keep whitespace inside
+// an argument, e.g. -api "Filter=tr/a-z/A-Z/".
+func splitToolArgs(value string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// checkToolArgs rejects arguments that conflict with the options infx
+// passes to tool itself, and for exiftool anything that would modify the
+// file: tag assignments such as -all= or -Artist=x, copies such as
+// -Artist<Creator, and the options in exiftoolWriteArgs.
+func checkToolArgs(tool string, args []string) error {
+	for _, arg := range args {
+		lower := strings.ToLower(arg)
+		if tool == "exiftool" && strings.HasPrefix(arg, "-") && exiftoolWrites(lower) {
+			return fmt.Errorf("%s is rejected: infx only reads metadata and must never modify the file", arg)
+		}
+		for _, reserved := range reservedToolArgs[tool] {
+			if lower == reserved || (strings.HasSuffix(reserved, "=") && strings.HasPrefix(lower, reserved)) {
+				return fmt.Errorf("%s is reserved: infx needs %s's JSON output", arg, tool)
+			}
+		}
+	}
+	return nil
+}
+
+// exiftoolWrites reports whether the lower-cased option arg makes
+// exiftool write.
+func exiftoolWrites(arg string) bool {
+	if strings.ContainsAny(arg, "=<>") {
+		return true
+	}
+	for _, write := range exiftoolWriteArgs {
+		if strings.HasPrefix(arg, write) {
+			return true
+		}
+	}
+	return false
+}
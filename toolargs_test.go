@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestCheckToolArgs(t *testing.T) {
+	tests := []struct {
+		tool string
+		args []string
+		ok   bool
+	}{
+		{"exiftool", []string{"-api", "LargeFileSupport=1"}, true},
+		{"exiftool", []string{"-ee", "-u", "-charset", "filename=utf8"}, true},
+		{"exiftool", []string{"-geolocation"}, true},
+		{"exiftool", []string{"-all="}, false},
+		{"exiftool", []string{"-Artist=x"}, false},
+		{"exiftool", []string{"-XMP:Title+=x"}, false},
+		{"exiftool", []string{"-Artist<Creator"}, false},
+		{"exiftool", []string{"-tagsFromFile", "other.jpg"}, false},
+		{"exiftool", []string{"-overwrite_original"}, false},
+		{"exiftool", []string{"-delete_original!"}, false},
+		{"exiftool", []string{"-geotag", "track.gpx"}, false},
+		{"exiftool", []string{"-json=tags.json"}, false},
+		{"exiftool", []string{"-w+", "%d%f.txt"}, false},
+		{"exiftool", []string{"-W!", "%d%f.txt"}, false},
+		{"exiftool", []string{"-execute"}, false},
+		{"exiftool", []string{"-j"}, false},
+		{"mediainfo", []string{"--Full"}, true},
+		{"mediainfo", []string{"--Output=XML"}, false},
+	}
+	for _, tt := range tests {
+		err := checkToolArgs(tt.tool, tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkToolArgs(%s, %q) = %v, want ok %v", tt.tool, tt.args, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+// TrailingData describes bytes found after a file's logical end marker.
+type TrailingData struct {
+	Offset   int64  `json:"offset"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type,omitempty"`
+}
+
+// minTrailingBytes is how much has to follow the end marker before it is
+// worth reporting; a stray byte or two is common writer sloppiness.
+const minTrailingBytes = 16
+
+var errNoEndMarker = errors.New("end marker not found")
+
+// detectTrailingData reports data appended after the end of a JPEG (EOI)
+// or PNG (IEND) stream. Other formats have no reliable end marker and are
+// not checked. Short runs of 0x00/0xFF padding are ignored; anything else
+// is reported with its offset, size and sniffed type. Note that JPEGs
+// carrying MPF secondary images or motion photo video legitimately keep
+// them after the first EOI and will be reported too.
+func detectTrailingData(filePath, mimeType string, fileSize int64) *TrailingData {
+	var endOf func(io.Reader) (int64, error)
+	switch mimeType {
+	case "image/jpeg":
+		endOf = jpegEnd
+	case "image/png":
+		endOf = pngEnd
+	default:
+		return nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	end, err := endOf(bufio.NewReader(f))
+	if err != nil || fileSize-end < minTrailingBytes {
+		return nil
+	}
+
+	trailer := make([]byte, sniffLen)
+	n, _ := f.ReadAt(trailer, end)
+	trailer = trailer[:n]
+	if int64(n) == fileSize-end && isPadding(trailer) {
+		return nil
+	}
+	return &TrailingData{
+		Offset:   end,
+		Size:     fileSize - end,
+		MimeType: baseMime(http.DetectContentType(trailer)),
+	}
+}
+
+func isPadding(data []byte) bool {
+	return len(bytes.Trim(data, "\x00\xff")) == 0
+}
+
+// jpegEnd walks the JPEG marker segments, scanning entropy-coded data
+// after each SOS, and returns the offset just past the EOI marker.
+func jpegEnd(r io.Reader) (int64, error) {
+	br := &countingReader{r: bufio.NewReader(r)}
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return 0, errNoEndMarker
+	}
+
+	for {
+		marker, err := br.nextMarker()
+		if err != nil {
+			return 0, errNoEndMarker
+		}
+		switch {
+		case marker == 0xD9:
+			return br.n, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			continue
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(br, length[:]); err != nil {
+			return 0, errNoEndMarker
+		}
+		segment := int64(binary.BigEndian.Uint16(length[:])) - 2
+		if segment < 0 {
+			return 0, errNoEndMarker
+		}
+		if _, err := io.CopyN(io.Discard, br, segment); err != nil {
+			return 0, errNoEndMarker
+		}
+		if marker == 0xDA {
+			if err := br.skipEntropyData(); err != nil {
+				return 0, errNoEndMarker
+			}
+		}
+	}
+}
+
+// pngEnd walks the PNG chunk list and returns the offset just past the
+// IEND chunk's CRC.
+func pngEnd(r io.Reader) (int64, error) {
+	br := &countingReader{r: bufio.NewReader(r)}
+	var sig [8]byte
+	if _, err := io.ReadFull(br, sig[:]); err != nil || string(sig[:]) != "\x89PNG\r\n\x1a\n" {
+		return 0, errNoEndMarker
+	}
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return 0, errNoEndMarker
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		if _, err := io.CopyN(io.Discard, br, length+4); err != nil {
+			return 0, errNoEndMarker
+		}
+		if string(header[4:]) == "IEND" {
+			return br.n, nil
+		}
+	}
+}
+
+// countingReader tracks the offset of a buffered reader and holds back a
+// marker found while scanning JPEG entropy-coded data.
+type countingReader struct {
+	r       *bufio.Reader
+	n       int64
+	pending byte
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) readByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// nextMarker returns the next marker code, skipping fill bytes.
+func (c *countingReader) nextMarker() (byte, error) {
+	if c.pending != 0 {
+		marker := c.pending
+		c.pending = 0
+		return marker, nil
+	}
+	b, err := c.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, errNoEndMarker
+	}
+	for b == 0xFF {
+		if b, err = c.readByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+// skipEntropyData reads scan data up to the next real marker, which is
+// left pending. Stuffed zero bytes and restart markers belong to the scan.
+func (c *countingReader) skipEntropyData() error {
+	for {
+		b, err := c.readByte()
+		if err != nil {
+			return err
+		}
+		if b != 0xFF {
+			continue
+		}
+		for b == 0xFF {
+			if b, err = c.readByte(); err != nil {
+				return err
+			}
+		}
+		if b == 0x00 || (b >= 0xD0 && b <= 0xD7) {
+			continue
+		}
+		c.pending = b
+		return nil
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "unicode/utf8"
+
+// truncationMarker is appended to EXIF values cut by --max-exif-value-len.
+const truncationMarker = "…(truncated)"
+
+// truncateExif shortens every string value of exif longer than max
+// characters, in place, and returns the original length in characters of
+// each value it cut, or nil if none was.
+func truncateExif(exif map[string]interface{}, max int) map[string]int {
+	var truncated map[string]int
+	for key, value := range exif {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		length := utf8.RuneCountInString(s)
+		if length <= max {
+			continue
+		}
+		cut, n := 0, 0
+		for i := range s {
+			if n == max {
+				cut = i
+				break
+			}
+			n++
+		}
+		exif[key] = s[:cut] + truncationMarker
+		if truncated == nil {
+			truncated = make(map[string]int)
+		}
+		truncated[key] = length
+	}
+	return truncated
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// currentBuildInfo returns the ldflags values, falling back to what the
+// Go toolchain embedded in the binary for anything that wasn't set.
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// toolVersion asks an external tool for its version, returning "" if it
+// can't be run.
+func toolVersion(tool string) string {
+	switch tool {
+	case "exiftool":
+		out, err := runCommand("exiftool", "-ver")
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	case "mediainfo":
+		out, err := runCommand("mediainfo", "--Version")
+		if err != nil {
+			return ""
+		}
+		// "MediaInfo Command line,\nMediaInfoLib - v23.04"
+		for _, line := range strings.Split(string(out), "\n") {
+			if _, v, ok := strings.Cut(line, " - "); ok {
+				return strings.TrimSpace(v)
+			}
+		}
+		return strings.TrimSpace(string(out))
+	}
+	return ""
+}
+
+func printVersion() {
+	info := currentBuildInfo()
+	fmt.Printf("infx %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Printf("commit:     %s\n", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("build date: %s\n", info.BuildDate)
+	}
+	fmt.Printf("go:         %s\n", info.GoVersion)
+	for _, tool := range []string{"exiftool", "mediainfo"} {
+		v := toolVersion(tool)
+		if v == "" {
+			v = "not found"
+		}
+		fmt.Printf("%-11s %s\n", tool+":", v)
+	}
+}
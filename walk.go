@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walker finds the files to analyze below a set of roots. Symbolic links
+// are skipped unless followSymlinks is set, in which case directories are
+// remembered by identity so a link cycle can't make the walk loop.
+type walker struct {
+	followSymlinks bool
+	visited        map[string]bool
+
+	// include and exclude are filepath.Match patterns tested against base
+	// names. Files must match an include pattern, if any are given, and no
+	// exclude pattern; excluded directories are not descended into.
+	include  []string
+	exclude  []string
+	foldCase bool
+
+	// irregular also visits named pipes, devices and sockets, which are
+	// otherwise passed over.
+	irregular bool
+
+	// skipHidden excludes files and directories whose name starts with
+	// a dot, as if it were one more exclude pattern.
+	skipHidden bool
+
+	// visit is called for every regular file found, and with irregular
+	// set every pipe, device and socket. resolved is the link target when
+	// path was reached through a symlink, otherwise "".
+	// err reports a path that couldn't be read.
+	visit func(path, resolved string, err error)
+}
+
+func newWalker(followSymlinks bool, visit func(path, resolved string, err error)) *walker {
+	return &walker{
+		followSymlinks: followSymlinks,
+		visited:        make(map[string]bool),
+		visit:          visit,
+	}
+}
+
+// walk reports the files below root. A root that is itself a symlink is
+// followed even without followSymlinks, as find -H does, since it was
+// named explicitly.
+func (w *walker) walk(root string) {
+	if info, err := os.Lstat(root); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		w.followRoot(root)
+		return
+	}
+	w.walkDir(root, root, false)
+}
+
+func (w *walker) followRoot(root string) {
+	target, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		w.visit(root, "", err)
+		return
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		w.visit(root, target, err)
+		return
+	}
+	switch {
+	case info.IsDir():
+		w.walkDir(target, root, true)
+	case info.Mode().IsRegular(), w.irregular && isIrregular(info.Mode()):
+		w.visit(root, target, nil)
+	}
+}
+
+// walkDir walks dir, reporting the files below it under logical, the
+// path the user will recognize. The two differ once we descend through a
+// symlinked directory.
+func (w *walker) walkDir(dir, logical string, viaLink bool) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		logicalPath := logical
+		if rel, relErr := filepath.Rel(dir, path); relErr == nil && rel != "." {
+			logicalPath = filepath.Join(logical, rel)
+		}
+		resolved := ""
+		if viaLink {
+			resolved = path
+		}
+
+		if err != nil {
+			w.visit(logicalPath, resolved, err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		isRoot := path == dir
+		switch {
+		case d.IsDir():
+			if !isRoot && w.excluded(d.Name()) {
+				return fs.SkipDir
+			}
+			if info, err := d.Info(); err == nil && w.seen(path, info) {
+				return fs.SkipDir
+			}
+		case d.Type()&fs.ModeSymlink != 0:
+			if w.followSymlinks && !w.excluded(d.Name()) {
+				w.followLink(path, logicalPath)
+			}
+		case d.Type().IsRegular(), w.irregular && isIrregular(d.Type()):
+			if isRoot || w.wanted(d.Name()) {
+				w.visit(logicalPath, resolved, nil)
+			}
+		}
+		return nil
+	})
+}
+
+func (w *walker) followLink(path, logical string) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		w.visit(logical, "", err)
+		return
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		w.visit(logical, target, err)
+		return
+	}
+	switch {
+	case info.IsDir():
+		w.walkDir(target, logical, true)
+	case info.Mode().IsRegular(), w.irregular && isIrregular(info.Mode()):
+		if w.wanted(filepath.Base(logical)) {
+			w.visit(logical, target, nil)
+		}
+	}
+}
+
+// wanted reports whether a file passes the include and exclude filters.
+func (w *walker) wanted(name string) bool {
+	if w.excluded(name) {
+		return false
+	}
+	return len(w.include) == 0 || w.matchAny(w.include, name)
+}
+
+func (w *walker) excluded(name string) bool {
+	if w.skipHidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+	return w.matchAny(w.exclude, name)
+}
+
+func (w *walker) matchAny(patterns []string, name string) bool {
+	if w.foldCase {
+		name = strings.ToLower(name)
+	}
+	for _, pattern := range patterns {
+		if w.foldCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// seen records a directory and reports whether it was visited before.
+func (w *walker) seen(path string, info os.FileInfo) bool {
+	id, ok := fileIdentity(info)
+	if !ok {
+		// no inode numbers on this platform; the canonical path is
+		// the next best identity
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return false
+		}
+		id = resolved
+	}
+	if w.visited[id] {
+		return true
+	}
+	w.visited[id] = true
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// WebPInfo describes the variant of a WebP file, read from its RIFF
+// chunks.
+type WebPInfo struct {
+	IsLossless bool `json:"is_lossless"`
+	HasAlpha   bool `json:"has_alpha"`
+	HasICC     bool `json:"has_icc"`
+	IsAnimated bool `json:"is_animated"`
+}
+
+// VP8X feature flags.
+const (
+	vp8xAnimation = 0x02
+	vp8xAlpha     = 0x10
+	vp8xICC       = 0x20
+)
+
+// maxWebPChunks bounds the chunk walk of extractWebP.
+const maxWebPChunks = 64
+
+// extractWebP reads the RIFF chunk headers of a WebP file. A simple file
+// is one VP8 (lossy) or VP8L (lossless) chunk; an extended one starts with
+// a VP8X chunk whose flags announce alpha, an ICC profile and animation,
+// followed by the image, or for animations ANMF frames wrapping one each.
+// Lossless is decided by the first image found. It returns nil if the
+// file isn't a WebP it can parse.
+func extractWebP(filePath string) *WebPInfo {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var header [12]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil || string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return nil
+	}
+
+	info := &WebPInfo{}
+	for i := 0; i < maxWebPChunks; i++ {
+		var chunk [8]byte
+		if _, err := io.ReadFull(f, chunk[:]); err != nil {
+			break
+		}
+		kind := string(chunk[:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		// chunks are padded to an even size
+		next := size + size&1
+
+		switch kind {
+		case "VP8X":
+			var flags [1]byte
+			if _, err := io.ReadFull(f, flags[:]); err != nil {
+				return nil
+			}
+			info.IsAnimated = flags[0]&vp8xAnimation != 0
+			info.HasAlpha = flags[0]&vp8xAlpha != 0
+			info.HasICC = flags[0]&vp8xICC != 0
+			next--
+		case "VP8 ":
+			return info
+		case "VP8L":
+			info.IsLossless = true
+			// without VP8X, the alpha_is_used bit of the VP8L header
+			// is all there is
+			var vp8l [5]byte
+			if _, err := io.ReadFull(f, vp8l[:]); err == nil && vp8l[0] == 0x2F {
+				info.HasAlpha = info.HasAlpha || vp8l[4]&0x10 != 0
+			}
+			return info
+		case "ANMF":
+			// the frame header is 16 bytes, then the frame's own chunks
+			var frame [24]byte
+			if _, err := io.ReadFull(f, frame[:]); err != nil {
+				return info
+			}
+			info.IsLossless = string(frame[16:20]) == "VP8L"
+			return info
+		}
+		if _, err := f.Seek(next, io.SeekCurrent); err != nil {
+			break
+		}
+	}
+	return info
+}